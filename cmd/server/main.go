@@ -2,36 +2,187 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Port time zones are validated, the distroless image has no zone database.
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver for the sql store, the image is built without cgo.
 
 	"ports-service/internal/adapters/database"
 	"ports-service/internal/adapters/grpc"
 	"ports-service/internal/adapters/streamfromfile"
 	"ports-service/internal/domain"
+	"ports-service/internal/ports"
 )
 
 func main() {
 	runGRPC := flag.Bool("grpc", true, "Whether to run gRPC server")
 	bufferSize := flag.Int("buffer", 100, "Size of buffered channel to limit memory usage")
+	workers := flag.Int("workers", 4, "Number of goroutines storing the ports of each gRPC stream")
+	maxErrorDetails := flag.Int("max-error-details", grpc.DefaultMaxErrorDetails, "Number of rejected ports detailed in the summary of a StreamPorts call")
+	enqueueTimeout := flag.Duration("enqueue-timeout", 10*time.Second, "How long a gRPC stream waits for room in its saturated ingest pipeline, 0 waits indefinitely")
 	filePath := flag.String("file", "data/ports.json", "Path to JSON file")
+	merge := flag.Bool("merge", false, "Treat every entry of the JSON file as a merge patch, only updating the fields it carries")
+	errorPolicy := flag.String("error-policy", string(streamfromfile.FailFast), "What to do with malformed entries of the JSON file: fail-fast, skip or dead-letter to skip and record them")
+	deadLetterPath := flag.String("dead-letter-path", "ports-dead-letter.ndjson", "File the dead-letter error policy writes malformed entries of the JSON file to")
 	debugKey := flag.String("debugkey", "ZWUTA", "Key to lookup in the database")
 	address := flag.String("address", ":8080", "Address to run gRPC server on")
+	store := flag.String("store", "memory", "Where ports are stored: memory, file or sql to persist them across restarts")
+	storePath := flag.String("store-path", "ports.log", "Log file of the file store")
+	historyPath := flag.String("history-path", "ports-history.log", "Log file of the revision history kept by the file store")
+	storeSyncInterval := flag.Duration("store-sync-interval", 0, "How often the file store syncs writes to disk, 0 syncs every write")
+	storeCompactInterval := flag.Duration("store-compact-interval", time.Minute, "How often the file store checks whether to compact its log, 0 disables compaction")
+	snapshotDir := flag.String("snapshot-dir", "", "Directory to snapshot the stored ports to and restore them from on startup, empty disables snapshots")
+	snapshotInterval := flag.Duration("snapshot-interval", 5*time.Minute, "How often a snapshot is taken, 0 only snapshots on shutdown")
+	snapshotFormat := flag.String("snapshot-format", "json", "Format of new snapshots: json, keyed like the ports file, or ndjson")
+	snapshotKeep := flag.Int("snapshot-keep", 3, "Number of snapshots kept in the snapshot directory")
+	walDir := flag.String("wal-dir", "", "Directory of the write-ahead log every stored port is logged to before it is acknowledged, empty disables the log")
+	walSegmentSize := flag.Int64("wal-segment-size", database.DefaultWALSegmentSize, "Size in bytes after which the write-ahead log starts a new segment")
+	walRetention := flag.Duration("wal-retention", time.Hour, "How long write-ahead log segments are kept once a snapshot covers them")
+	eventLogSize := flag.Int("event-log-size", database.DefaultEventLogSize, "Number of recent port changes kept for WatchPorts clients resuming after a reconnect")
+	tombstoneRetention := flag.Duration("tombstone-retention", 7*24*time.Hour, "How long deleted ports can be restored before they are purged")
+	purgeInterval := flag.Duration("purge-interval", time.Hour, "How often deleted ports older than -tombstone-retention are purged, 0 never purges them")
+	sqlDSN := flag.String("sql-dsn", "file:ports.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", "SQLite data source name of the sql store")
 
 	flag.Parse()
 
 	// TODO: consider using slog package for structured logging
 	log.Println("Run gRPC server:", *runGRPC)
 	log.Println("Buffer size:", *bufferSize)
+	log.Println("Workers per stream:", *workers)
+	log.Println("Enqueue timeout:", *enqueueTimeout)
 	log.Println("File path:", *filePath)
 	log.Println("Debug key:", *debugKey)
+	log.Println("Store:", *store)
+
+	geoIndex := database.NewGeoIndex()
+	searchIndex := database.NewSearchIndex()
+	eventLog := database.NewEventLog(*eventLogSize)
+	memIndexes := []database.Index[domain.Port]{geoIndex, searchIndex, eventLog}
+	fieldIndexes := make(map[domain.PortField]domain.PortIndex, len(domain.LookupFields))
+	for _, field := range domain.LookupFields {
+		index := database.NewFieldIndex(field.Values)
+		memIndexes = append(memIndexes, index)
+		fieldIndexes[field] = index
+	}
+
+	var db ports.Store[domain.Port]
+	var history domain.PortHistoryStore
+	switch *store {
+	case "memory":
+		db = database.NewMemDB[domain.Port](memIndexes...)
+		history = database.NewHistoryStore(database.NewMemDB[[]domain.PortRevision]())
+	case "file":
+		fileDB, err := database.OpenFileDB[domain.Port](*storePath, database.FileDBOptions{
+			SyncInterval:    *storeSyncInterval,
+			CompactInterval: *storeCompactInterval,
+		}, memIndexes...)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer func() {
+			if err := fileDB.Close(); err != nil {
+				log.Println("Error closing store:", err)
+			}
+		}()
+		log.Printf("Loaded %d ports from %s", fileDB.Len(), *storePath)
+		db = fileDB
+		historyDB, err := database.OpenFileDB[[]domain.PortRevision](*historyPath, database.FileDBOptions{
+			SyncInterval:    *storeSyncInterval,
+			CompactInterval: *storeCompactInterval,
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer func() {
+			if err := historyDB.Close(); err != nil {
+				log.Println("Error closing history:", err)
+			}
+		}()
+		history = database.NewHistoryStore(historyDB)
+	case "sql":
+		sqlDB, err := sql.Open("sqlite", *sqlDSN)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer sqlDB.Close()
+		portsDB, err := database.OpenSQLDB(context.Background(), sqlDB, memIndexes...)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		db = portsDB
+		history = portsDB
+	default:
+		log.Fatalf("Unknown store %q, use memory, file or sql", *store)
+	}
+
+	var snapshotter *database.SnapshotDir
+	if *snapshotDir != "" {
+		snapshots, ok := db.(database.Snapshotter)
+		if !ok {
+			log.Fatalf("The %s store does not support snapshots", *store)
+		}
+		format, err := database.ParseSnapshotFormat(*snapshotFormat)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		dir := database.SnapshotDir{Dir: *snapshotDir, Format: format, Keep: *snapshotKeep}
+
+		// A store persisting itself already holds newer ports than its last
+		// snapshot, so only an empty store is restored. Restore before
+		// serving, so no request sees a partially restored store.
+		empty, err := database.IsEmpty(context.Background(), db)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if !empty {
+			log.Printf("Not restoring a snapshot, the %s store already holds ports", *store)
+		} else {
+			path, n, err := dir.RestoreLatest(context.Background(), snapshots)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if path != "" {
+				log.Printf("Restored %d ports from snapshot %s", n, path)
+			}
+		}
+		snapshotter = &dir
+	}
 
-	db := database.MemDB[domain.Port]{
-		DB: make(map[string]domain.Port),
+	if *walDir != "" {
+		// Replays the writes since the restored snapshot.
+		wal, err := database.OpenWALStore[domain.Port](*walDir, database.WALOptions{
+			SegmentSize: *walSegmentSize,
+			Retention:   *walRetention,
+		}, db)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer wal.Close()
+		db = wal
+	}
+
+	if dir := snapshotter; dir != nil {
+		snapshots := db.(database.Snapshotter)
+		ctx, cancel := context.WithCancel(context.Background())
+		if *snapshotInterval > 0 {
+			go dir.Run(ctx, snapshots, *snapshotInterval)
+		}
+		defer func() {
+			cancel()
+			path, n, err := dir.Save(context.Background(), snapshots)
+			if err != nil {
+				log.Println("Error saving snapshot:", err)
+				return
+			}
+			log.Printf("Saved snapshot of %d ports to %s", n, path)
+		}()
 	}
 
 	// TODO: move this to separate package
@@ -48,8 +199,8 @@ func main() {
 					ticker.Stop()
 					return
 				case <-ticker.C:
-					port, ok := db.DB[*debugKey]
-					if ok {
+					port, err := db.Get(ctx, *debugKey)
+					if err == nil {
 						log.Printf("Lookup key found %s: %v\n", *debugKey, port)
 						return
 					} else {
@@ -60,22 +211,66 @@ func main() {
 		}()
 	}
 
-	repo := domain.StorePortRepository{Data: &db}
+	repo := domain.StorePortRepository{Data: db, Locator: geoIndex, Indexes: fieldIndexes, Searcher: searchIndex, Events: eventLog, History: history}
+
+	if *purgeInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go func() {
+			ticker := time.NewTicker(*purgeInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case now := <-ticker.C:
+					purged, err := repo.PurgeDeleted(ctx, now.Add(-*tombstoneRetention))
+					if err != nil {
+						log.Println("Error purging deleted ports:", err)
+					}
+					if purged > 0 {
+						log.Printf("Purged %d ports deleted more than %s ago", purged, *tombstoneRetention)
+					}
+				}
+			}
+		}()
+	}
 
 	if *runGRPC {
 		portService := grpc.PortService{PortForShipsRepository: repo}
-		err := grpc.StartServer(*address, portService, *bufferSize)
+		config := grpc.Config{
+			BufferSize:      *bufferSize,
+			Workers:         *workers,
+			EnqueueTimeout:  *enqueueTimeout,
+			MaxErrorDetails: *maxErrorDetails,
+		}
+		err := grpc.StartServer(*address, portService, config)
 		if err != nil {
-			log.Fatalln(err)
+			log.Println(err)
+			return
 		}
 	} else {
-		portService := streamfromfile.PortService{PortForShipsRepository: repo}
+		policy, err := streamfromfile.ParseErrorPolicy(*errorPolicy)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		portService := streamfromfile.PortService{
+			PortForShipsRepository: repo,
+			Merge:                  *merge,
+			ErrorPolicy:            policy,
+			DeadLetterPath:         *deadLetterPath,
+		}
 		ctx, cancel := context.WithCancel(context.Background())
 
 		// Start streaming
-		err := portService.StreamJSONfromFile(ctx, *filePath, *bufferSize)
-		if err != nil {
-			log.Fatalln(err)
+		err = portService.StreamJSONfromFile(ctx, *filePath, *bufferSize)
+		if errors.Is(err, domain.ErrInvalidPort) {
+			// The valid ports have been stored, keep serving them.
+			log.Println(err)
+		} else if err != nil {
+			log.Println(err)
+			return
 		}
 
 		// Wait for SIGINT (Ctrl+C)
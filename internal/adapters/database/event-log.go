@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ports-service/internal/domain"
+)
+
+// DefaultEventLogSize is used when NewEventLog is given no size.
+const DefaultEventLogSize = 10000
+
+// EventLog records the changes to the stored Ports as domain.PortEvents and
+// feeds them to watchers; it implements domain.PortEventSource. Register it
+// with a store like the other indexes, so it sees every write in the order
+// the store applied it, with the replaced value.
+//
+// The newest events are kept in memory, so a watcher that reconnects can
+// resume after the last event it handled. Resume tokens carry the time the
+// EventLog was created, tokens of an earlier process are reported as
+// expired. An EventLog is safe for concurrent use.
+type EventLog struct {
+	mu     sync.Mutex
+	size   int
+	epoch  string
+	events []domain.PortEvent // Oldest first.
+	first  uint64             // Sequence number of events[0].
+	next   uint64             // Sequence number of the next event.
+	notify chan struct{}      // Closed, and replaced, when an event is added.
+}
+
+// NewEventLog returns an empty EventLog keeping the newest size events.
+func NewEventLog(size int) *EventLog {
+	if size <= 0 {
+		size = DefaultEventLogSize
+	}
+	return &EventLog{
+		size:   size,
+		epoch:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		first:  1,
+		next:   1,
+		notify: make(chan struct{}),
+	}
+}
+
+// Put records the creation or update of the Port under key.
+func (l *EventLog) Put(key string, old *domain.Port, value domain.Port) {
+	l.record(key, old, &value)
+}
+
+// Remove records the deletion of the Port under key.
+func (l *EventLog) Remove(key string, old domain.Port) {
+	l.record(key, &old, nil)
+}
+
+// record adds the event of a change from old to value, treating tombstones
+// like no Port: deleting a Port records its deletion, restoring it its
+// creation, and purging its tombstone nothing.
+func (l *EventLog) record(key string, old, value *domain.Port) {
+	if old != nil && old.Deleted() {
+		old = nil
+	}
+	if value != nil && value.Deleted() {
+		value = nil
+	}
+	if old == nil && value == nil {
+		return
+	}
+	l.add(domain.NewPortEvent(key, old, value))
+}
+
+func (l *EventLog) add(event domain.PortEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event.ResumeToken = l.token(l.next)
+	l.events = append(l.events, event)
+	l.next++
+	if len(l.events) > l.size {
+		// Drop the oldest events in bulk, so adding stays cheap.
+		drop := len(l.events) - l.size + l.size/4
+		l.events = append([]domain.PortEvent(nil), l.events[drop:]...)
+		l.first += uint64(drop)
+	}
+
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// ResumeToken returns the position of the last recorded event, watching
+// from it yields the events recorded afterwards.
+func (l *EventLog) ResumeToken() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.token(l.next - 1)
+}
+
+func (l *EventLog) token(seq uint64) string {
+	return l.epoch + "." + strconv.FormatUint(seq, 10)
+}
+
+// position returns the sequence number resumeToken was handed out for.
+func (l *EventLog) position(resumeToken string) (uint64, error) {
+	epoch, seq, ok := strings.Cut(resumeToken, ".")
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", domain.ErrInvalidResumeToken, resumeToken)
+	}
+	position, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", domain.ErrInvalidResumeToken, resumeToken)
+	}
+	if epoch != l.epoch {
+		return 0, fmt.Errorf("%w: %q was handed out before a restart", domain.ErrResumeTokenExpired, resumeToken)
+	}
+	if position >= l.next {
+		return 0, fmt.Errorf("%w: %q", domain.ErrInvalidResumeToken, resumeToken)
+	}
+	return position, nil
+}
+
+// Watch calls fn with every event after resumeToken until ctx is done or fn
+// fails, see domain.PortEventSource. A watcher falling so far behind that
+// the events it has yet to see are dropped fails with
+// domain.ErrResumeTokenExpired.
+func (l *EventLog) Watch(ctx context.Context, resumeToken string, fn func(domain.PortEvent) error) error {
+	l.mu.Lock()
+	last := l.next - 1
+	if resumeToken != "" {
+		var err error
+		if last, err = l.position(resumeToken); err != nil {
+			l.mu.Unlock()
+			return err
+		}
+	}
+	l.mu.Unlock()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		l.mu.Lock()
+		if last+1 < l.first {
+			l.mu.Unlock()
+			return fmt.Errorf("%w: events after %q have been dropped", domain.ErrResumeTokenExpired, l.token(last))
+		}
+		batch := append([]domain.PortEvent(nil), l.events[last+1-l.first:]...)
+		notify := l.notify
+		l.mu.Unlock()
+
+		for _, event := range batch {
+			if err := fn(event); err != nil {
+				return err
+			}
+			last++
+		}
+		if len(batch) > 0 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-notify:
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/domain"
+)
+
+var errStopWatching = errors.New("stop watching")
+
+// watchN returns the next n events after resumeToken.
+func watchN(t *testing.T, events *database.EventLog, resumeToken string, n int) []domain.PortEvent {
+	t.Helper()
+
+	var seen []domain.PortEvent
+	err := events.Watch(context.Background(), resumeToken, func(event domain.PortEvent) error {
+		seen = append(seen, event)
+		if len(seen) == n {
+			return errStopWatching
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, errStopWatching)
+	return seen
+}
+
+func TestEventLog_Watch(t *testing.T) {
+	ctx := context.Background()
+	events := database.NewEventLog(0)
+	db := database.NewMemDB[domain.Port](events)
+	start := events.ResumeToken()
+
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Country: "Netherlands"}
+	renamed := domain.Port{Key: "NLRTM", Name: "Rotterdam Europoort", Country: "Netherlands"}
+	require.NoError(t, db.Set(ctx, "NLRTM", rotterdam))
+	require.NoError(t, db.Set(ctx, "NLRTM", renamed))
+	require.NoError(t, db.Delete(ctx, "NLRTM"))
+
+	seen := watchN(t, events, start, 3)
+	assert.Equal(t, domain.PortCreated, seen[0].Type)
+	assert.Nil(t, seen[0].Old)
+	assert.Equal(t, &rotterdam, seen[0].New)
+	assert.Equal(t, domain.PortUpdated, seen[1].Type)
+	assert.Equal(t, &rotterdam, seen[1].Old)
+	assert.Equal(t, &renamed, seen[1].New)
+	assert.Equal(t, domain.PortDeleted, seen[2].Type)
+	assert.Equal(t, &renamed, seen[2].Old)
+	assert.Nil(t, seen[2].New)
+
+	// Resuming after an event yields the ones after it.
+	resumed := watchN(t, events, seen[0].ResumeToken, 2)
+	assert.Equal(t, seen[1:], resumed)
+
+	// A running watch receives new events as they are recorded.
+	watched := make(chan []domain.PortEvent)
+	go func() {
+		var next []domain.PortEvent
+		_ = events.Watch(ctx, seen[2].ResumeToken, func(event domain.PortEvent) error {
+			next = append(next, event)
+			return errStopWatching
+		})
+		watched <- next
+	}()
+	require.NoError(t, db.Set(ctx, "DEHAM", domain.Port{Key: "DEHAM"}))
+	select {
+	case next := <-watched:
+		require.Len(t, next, 1)
+		assert.Equal(t, "DEHAM", next[0].Key)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not receive the event")
+	}
+}
+
+func TestEventLog_Tombstones(t *testing.T) {
+	ctx := context.Background()
+	events := database.NewEventLog(0)
+	db := database.NewMemDB[domain.Port](events)
+	start := events.ResumeToken()
+
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam"}
+	deletedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tombstone := rotterdam
+	tombstone.DeletedAt = &deletedAt
+	require.NoError(t, db.Set(ctx, "NLRTM", rotterdam))
+	require.NoError(t, db.Set(ctx, "NLRTM", tombstone))
+	require.NoError(t, db.Set(ctx, "NLRTM", rotterdam))
+	require.NoError(t, db.Set(ctx, "NLRTM", tombstone))
+	require.NoError(t, db.Delete(ctx, "NLRTM")) // Purging the tombstone is no event.
+	require.NoError(t, db.Set(ctx, "DEHAM", domain.Port{Key: "DEHAM"}))
+
+	seen := watchN(t, events, start, 5)
+	types := make([]domain.PortEventType, 0, len(seen))
+	for _, event := range seen {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []domain.PortEventType{
+		domain.PortCreated, domain.PortDeleted, domain.PortCreated, domain.PortDeleted, domain.PortCreated,
+	}, types)
+	assert.Equal(t, &rotterdam, seen[1].Old)
+	assert.Nil(t, seen[1].New)
+	assert.Equal(t, "DEHAM", seen[4].Key)
+}
+
+func TestEventLog_WatchStopsWithContext(t *testing.T) {
+	events := database.NewEventLog(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := events.Watch(ctx, "", func(domain.PortEvent) error { return nil })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestEventLog_InvalidResumeTokens(t *testing.T) {
+	ctx := context.Background()
+	events := database.NewEventLog(4)
+	db := database.NewMemDB[domain.Port](events)
+	start := events.ResumeToken()
+	for _, key := range []string{"A", "B", "C", "D", "E"} {
+		require.NoError(t, db.Set(ctx, key, domain.Port{Key: key}))
+	}
+
+	watch := func(resumeToken string) error {
+		return events.Watch(ctx, resumeToken, func(domain.PortEvent) error { return errStopWatching })
+	}
+	assert.ErrorIs(t, watch("garbage"), domain.ErrInvalidResumeToken)
+	// The oldest events have been dropped to keep the newest 4.
+	assert.ErrorIs(t, watch(start), domain.ErrResumeTokenExpired)
+	// Tokens of another EventLog, like the one before a restart, expired.
+	assert.ErrorIs(t, watch(database.NewEventLog(0).ResumeToken()), domain.ErrResumeTokenExpired)
+}
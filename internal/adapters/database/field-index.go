@@ -0,0 +1,86 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FieldIndex is a secondary index mapping the values of a field to the keys
+// of the entries carrying them. Values are matched ignoring case and
+// surrounding whitespace. Register it with NewMemDB to keep it in sync with
+// the stored entries; a FieldIndex[domain.Port] implements domain.PortIndex.
+type FieldIndex[T any] struct {
+	mu     sync.RWMutex
+	values func(T) []string
+	keys   map[string]map[string]struct{}
+}
+
+// NewFieldIndex returns an empty FieldIndex over the values values returns
+// for an entry. An entry may carry any number of values, including none.
+func NewFieldIndex[T any](values func(T) []string) *FieldIndex[T] {
+	return &FieldIndex[T]{
+		values: values,
+		keys:   make(map[string]map[string]struct{}),
+	}
+}
+
+// Put indexes the values of value under key, dropping those of old that
+// value no longer carries. A tombstone is not indexed.
+func (f *FieldIndex[T]) Put(key string, old *T, value T) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if old != nil {
+		f.remove(key, *old)
+	}
+	if isDeleted(value) {
+		return
+	}
+	for _, v := range f.values(value) {
+		v = normalizeFieldValue(v)
+		if v == "" {
+			continue
+		}
+		if f.keys[v] == nil {
+			f.keys[v] = make(map[string]struct{})
+		}
+		f.keys[v][key] = struct{}{}
+	}
+}
+
+// Remove drops the values of old indexed under key.
+func (f *FieldIndex[T]) Remove(key string, old T) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.remove(key, old)
+}
+
+func (f *FieldIndex[T]) remove(key string, old T) {
+	for _, v := range f.values(old) {
+		v = normalizeFieldValue(v)
+		delete(f.keys[v], key)
+		if len(f.keys[v]) == 0 {
+			delete(f.keys, v)
+		}
+	}
+}
+
+// Lookup returns the keys of the entries carrying value, in ascending order.
+func (f *FieldIndex[T]) Lookup(value string) []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	matches := f.keys[normalizeFieldValue(value)]
+	keys := make([]string, 0, len(matches))
+	for key := range matches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func normalizeFieldValue(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
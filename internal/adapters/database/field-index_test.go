@@ -0,0 +1,39 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/domain"
+)
+
+func TestFieldIndex(t *testing.T) {
+	ctx := context.Background()
+	alias := database.NewFieldIndex(domain.FieldAlias.Values)
+	country := database.NewFieldIndex(domain.FieldCountry.Values)
+	db := database.NewMemDB[domain.Port](alias, country)
+
+	require.NoError(t, db.Set(ctx, "AEAUH", domain.Port{Key: "AEAUH", Country: "United Arab Emirates", Alias: []string{"Abu Dhabi", "Abu Zaby"}}))
+	require.NoError(t, db.Set(ctx, "AEDXB", domain.Port{Key: "AEDXB", Country: "United Arab Emirates", Alias: []string{"Dubai"}}))
+	require.NoError(t, db.Set(ctx, "NLRTM", domain.Port{Key: "NLRTM", Country: "Netherlands"}))
+
+	assert.Equal(t, []string{"AEAUH", "AEDXB"}, country.Lookup("united arab emirates"))
+	assert.Equal(t, []string{"AEAUH"}, alias.Lookup(" ABU DHABI "))
+	assert.Empty(t, alias.Lookup("Rotterdam"))
+	assert.Empty(t, alias.Lookup(""))
+
+	// Overwriting drops the values the entry no longer carries.
+	require.NoError(t, db.Set(ctx, "AEAUH", domain.Port{Key: "AEAUH", Country: "UAE", Alias: []string{"Abu Zaby"}}))
+	assert.Empty(t, alias.Lookup("Abu Dhabi"))
+	assert.Equal(t, []string{"AEAUH"}, alias.Lookup("Abu Zaby"))
+	assert.Equal(t, []string{"AEDXB"}, country.Lookup("United Arab Emirates"))
+	assert.Equal(t, []string{"AEAUH"}, country.Lookup("UAE"))
+
+	require.NoError(t, db.Delete(ctx, "AEAUH"))
+	assert.Empty(t, alias.Lookup("Abu Zaby"))
+	assert.Empty(t, country.Lookup("UAE"))
+}
@@ -0,0 +1,463 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"ports-service/internal/ports"
+)
+
+// ErrClosed is returned by the writes of a FileDB after Close.
+var ErrClosed = errors.New("database closed")
+
+// FileDBOptions tunes the durability and compaction of a FileDB.
+type FileDBOptions struct {
+	// SyncInterval is how often written records are flushed to disk with
+	// fsync. Zero syncs every write before it returns, so a write that
+	// succeeded survives a crash.
+	SyncInterval time.Duration
+	// CompactInterval is how often the log is checked for compaction. It is
+	// rewritten once it holds more overwritten and deleted records than
+	// live ones. Zero disables periodic compaction, Compact still works.
+	CompactInterval time.Duration
+}
+
+const (
+	opSet    = "set"
+	opDelete = "delete"
+)
+
+// fileRecord is one line of the log of a FileDB or of a WALStore segment.
+type fileRecord[T any] struct {
+	Seq   uint64 `json:"seq,omitempty"` // Only set in a WALStore.
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Value *T     `json:"value,omitempty"`
+}
+
+// FileDB is a persistent ports.Store. Every write is appended to a log file
+// before it is applied to an in-memory MemDB, which serves all reads; opening
+// a FileDB replays the log. Each log line carries a CRC32 of its record, so
+// a record torn by a crash is detected and dropped on the next start.
+//
+// Compaction rewrites the log to a single record per live key in a
+// temporary file, which atomically replaces the log once it is synced.
+// A FileDB is safe for concurrent use; create one with OpenFileDB.
+type FileDB[T any] struct {
+	mu      sync.Mutex // Serializes writes, so the log and mem apply them in the same order.
+	path    string
+	opts    FileDBOptions
+	file    *os.File // nil once closed.
+	size    int64    // Bytes of the log known to hold whole records.
+	records int      // Records in the log, live or not.
+	dirty   bool     // Records written since the last fsync.
+	mem     *MemDB[T]
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// OpenFileDB opens the log at path, creating it if needed, and replays it.
+// The given indexes are maintained like those of NewMemDB. Close the FileDB
+// to flush and release the log.
+func OpenFileDB[T any](path string, opts FileDBOptions, indexes ...Index[T]) (*FileDB[T], error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open log: %w", err)
+	}
+
+	db := &FileDB[T]{
+		path: path,
+		opts: opts,
+		file: file,
+		mem:  NewMemDB[T](indexes...),
+		done: make(chan struct{}),
+	}
+	if err := db.replay(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(db.size, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("seek log: %w", err)
+	}
+
+	if opts.SyncInterval > 0 || opts.CompactInterval > 0 {
+		db.wg.Add(1)
+		go db.background()
+	}
+	return db, nil
+}
+
+// replay applies the records of the log to mem. A damaged last record is
+// what a crash in the middle of a write leaves behind, it is cut off; damage
+// anywhere else means the log is corrupt.
+func (db *FileDB[T]) replay() error {
+	reader := bufio.NewReader(db.file)
+	ctx := context.Background()
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return db.truncateTorn(len(line))
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read log: %w", err)
+		}
+
+		record, err := decodeRecord[T](line)
+		if err != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				return db.truncateTorn(len(line))
+			}
+			return fmt.Errorf("log %s corrupt at offset %d: %w", db.path, db.size, err)
+		}
+
+		switch record.Op {
+		case opSet:
+			err = db.mem.Set(ctx, record.Key, *record.Value)
+		case opDelete:
+			err = db.mem.Delete(ctx, record.Key)
+			if errors.Is(err, ports.ErrNotFound) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("replay log: %w", err)
+		}
+		db.size += int64(len(line))
+		db.records++
+	}
+}
+
+func (db *FileDB[T]) truncateTorn(length int) error {
+	log.Printf("Dropping %d bytes of a torn record at the end of %s", length, db.path)
+	if err := db.file.Truncate(db.size); err != nil {
+		return fmt.Errorf("truncate log: %w", err)
+	}
+	return nil
+}
+
+// encodeRecord renders record as a log line: the CRC32 of its JSON, a space,
+// the JSON and a newline.
+func encodeRecord[T any](record fileRecord[T]) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("encode record for key %s: %w", record.Key, err)
+	}
+	line := fmt.Appendf(nil, "%08x ", crc32.ChecksumIEEE(data))
+	line = append(line, data...)
+	return append(line, '\n'), nil
+}
+
+func decodeRecord[T any](line []byte) (fileRecord[T], error) {
+	var record fileRecord[T]
+	checksum, data, ok := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte(" "))
+	if !ok {
+		return record, errors.New("missing checksum")
+	}
+	if fmt.Sprintf("%08x", crc32.ChecksumIEEE(data)) != string(checksum) {
+		return record, errors.New("checksum mismatch")
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, err
+	}
+	if (record.Op == opSet && record.Value == nil) || (record.Op != opSet && record.Op != opDelete) {
+		return record, fmt.Errorf("invalid record %q for key %s", record.Op, record.Key)
+	}
+	return record, nil
+}
+
+// append writes line to the log, syncing it unless syncs are batched.
+// A failed write is cut off again, so no torn record precedes later ones.
+func (db *FileDB[T]) append(line []byte) error {
+	if db.file == nil {
+		return ErrClosed
+	}
+	if _, err := db.file.Write(line); err != nil {
+		if truncErr := db.file.Truncate(db.size); truncErr == nil {
+			_, _ = db.file.Seek(db.size, io.SeekStart)
+		}
+		return fmt.Errorf("append to log: %w", err)
+	}
+	db.size += int64(len(line))
+	db.records++
+
+	if db.opts.SyncInterval > 0 {
+		db.dirty = true
+		return nil
+	}
+	if err := db.file.Sync(); err != nil {
+		return fmt.Errorf("sync log: %w", err)
+	}
+	return nil
+}
+
+// Set persists value under key.
+func (db *FileDB[T]) Set(ctx context.Context, key string, value T) error {
+	_, err := db.Upsert(ctx, key, value)
+	return err
+}
+
+// Upsert persists value under key, unless an equal value is stored already.
+func (db *FileDB[T]) Upsert(ctx context.Context, key string, value T) (ports.UpsertOutcome, error) {
+	return db.Update(ctx, key, func(*T) (T, error) { return value, nil })
+}
+
+// Update persists the value fn derives from the one stored for key, see
+// ports.Store. fn is called with the write lock held, so it must not use db.
+func (db *FileDB[T]) Update(ctx context.Context, key string, fn func(old *T) (T, error)) (ports.UpsertOutcome, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	value, unchanged, err := derive(ctx, db.mem, key, fn)
+	if err != nil {
+		return 0, err
+	}
+	if unchanged {
+		return ports.Unchanged, nil
+	}
+	line, err := encodeRecord(fileRecord[T]{Op: opSet, Key: key, Value: &value})
+	if err != nil {
+		return 0, err
+	}
+	if err := db.append(line); err != nil {
+		return 0, err
+	}
+	return db.mem.Upsert(ctx, key, value)
+}
+
+// derive calls fn with the value store holds for key and reports whether
+// the derived value equals it.
+func derive[T any](ctx context.Context, store ports.Store[T], key string, fn func(old *T) (T, error)) (value T, unchanged bool, err error) {
+	old, err := store.Get(ctx, key)
+	switch {
+	case err == nil:
+		value, err = fn(&old)
+		return value, err == nil && equalValues(old, value), err
+	case errors.Is(err, ports.ErrNotFound):
+		value, err = fn(nil)
+		return value, false, err
+	default:
+		return value, false, err
+	}
+}
+
+// Get returns the value stored for key.
+func (db *FileDB[T]) Get(ctx context.Context, key string) (T, error) {
+	return db.mem.Get(ctx, key)
+}
+
+// Delete removes the value stored for key.
+func (db *FileDB[T]) Delete(ctx context.Context, key string) error {
+	_, err := db.DeleteIf(ctx, key, func(T) bool { return true })
+	return err
+}
+
+// DeleteIf removes the value stored for key if cond returns true for it.
+// cond is called with the write lock held, so it must not use db.
+func (db *FileDB[T]) DeleteIf(ctx context.Context, key string, cond func(T) bool) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	line, err := encodeRecord(fileRecord[T]{Op: opDelete, Key: key})
+	if err != nil {
+		return false, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	old, err := db.mem.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if !cond(old) {
+		return false, nil
+	}
+	if err := db.append(line); err != nil {
+		return false, err
+	}
+	return true, db.mem.Delete(ctx, key)
+}
+
+// List returns values in key order, see MemDB.List.
+func (db *FileDB[T]) List(ctx context.Context, opts ports.ListOptions[T]) (ports.Page[T], error) {
+	return db.mem.List(ctx, opts)
+}
+
+// Len returns the number of stored values.
+func (db *FileDB[T]) Len() int {
+	return db.mem.Len()
+}
+
+// Compact rewrites the log to hold a single record per stored value.
+func (db *FileDB[T]) Compact() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.compact()
+}
+
+func (db *FileDB[T]) compact() error {
+	if db.file == nil {
+		return ErrClosed
+	}
+
+	tmpPath := db.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("compact log: %w", err)
+	}
+	size, records, err := db.writeSnapshot(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, db.path)
+	}
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("compact log: %w", err)
+	}
+	if err := syncDir(filepath.Dir(db.path)); err != nil {
+		log.Printf("Error syncing directory of %s: %v", db.path, err)
+	}
+
+	// The compacted file is the log now, keep appending to it.
+	_ = db.file.Close()
+	db.file = tmp
+	db.size = size
+	db.records = records
+	db.dirty = false
+	return nil
+}
+
+// writeSnapshot writes a set record for every stored value to w, in key order.
+func (db *FileDB[T]) writeSnapshot(w io.Writer) (size int64, records int, err error) {
+	db.mem.mu.RLock()
+	defer db.mem.mu.RUnlock()
+
+	keys := make([]string, 0, len(db.mem.db))
+	for key := range db.mem.db {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := bufio.NewWriter(w)
+	for _, key := range keys {
+		value := db.mem.db[key]
+		line, err := encodeRecord(fileRecord[T]{Op: opSet, Key: key, Value: &value})
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, err := buf.Write(line); err != nil {
+			return 0, 0, err
+		}
+		size += int64(len(line))
+		records++
+	}
+	return size, records, buf.Flush()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// background syncs batched writes and compacts the log on the configured
+// intervals until Close.
+func (db *FileDB[T]) background() {
+	defer db.wg.Done()
+
+	var syncTick, compactTick <-chan time.Time
+	if db.opts.SyncInterval > 0 {
+		ticker := time.NewTicker(db.opts.SyncInterval)
+		defer ticker.Stop()
+		syncTick = ticker.C
+	}
+	if db.opts.CompactInterval > 0 {
+		ticker := time.NewTicker(db.opts.CompactInterval)
+		defer ticker.Stop()
+		compactTick = ticker.C
+	}
+
+	for {
+		select {
+		case <-db.done:
+			return
+		case <-syncTick:
+			db.mu.Lock()
+			if err := db.sync(); err != nil {
+				log.Printf("Error syncing %s: %v", db.path, err)
+			}
+			db.mu.Unlock()
+		case <-compactTick:
+			db.mu.Lock()
+			if stale := db.records - db.mem.Len(); stale > db.mem.Len() && db.file != nil {
+				if err := db.compact(); err != nil {
+					log.Printf("Error compacting %s: %v", db.path, err)
+				} else {
+					log.Printf("Compacted %s, dropped %d stale records", db.path, stale)
+				}
+			}
+			db.mu.Unlock()
+		}
+	}
+}
+
+func (db *FileDB[T]) sync() error {
+	if db.file == nil || !db.dirty {
+		return nil
+	}
+	if err := db.file.Sync(); err != nil {
+		return err
+	}
+	db.dirty = false
+	return nil
+}
+
+// Close stops the background work, syncs and closes the log. Reads keep
+// working, writes fail with ErrClosed.
+func (db *FileDB[T]) Close() error {
+	db.closeOnce.Do(func() { close(db.done) })
+	db.wg.Wait()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.file == nil {
+		return nil
+	}
+	err := db.sync()
+	if closeErr := db.file.Close(); err == nil {
+		err = closeErr
+	}
+	db.file = nil
+	return err
+}
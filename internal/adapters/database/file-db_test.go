@@ -0,0 +1,157 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/domain"
+	"ports-service/internal/ports"
+)
+
+func openFileDB(t *testing.T, path string, opts database.FileDBOptions, indexes ...database.Index[domain.Port]) *database.FileDB[domain.Port] {
+	t.Helper()
+	db, err := database.OpenFileDB[domain.Port](path, opts, indexes...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestFileDB_ReloadsOnOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.log")
+	ctx := context.Background()
+
+	db := openFileDB(t, path, database.FileDBOptions{})
+	require.NoError(t, db.Set(ctx, "NLRTM", domain.Port{Key: "NLRTM", Name: "Rotterdam", Coordinates: &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}}))
+	require.NoError(t, db.Set(ctx, "DEHAM", domain.Port{Key: "DEHAM", Name: "Hamburg"}))
+	require.NoError(t, db.Set(ctx, "NLRTM", domain.Port{Key: "NLRTM", Name: "Rotterdam Europoort"}))
+	require.NoError(t, db.Delete(ctx, "DEHAM"))
+	assert.ErrorIs(t, db.Delete(ctx, "DEHAM"), ports.ErrNotFound)
+	require.NoError(t, db.Close())
+	assert.ErrorIs(t, db.Set(ctx, "DEHAM", domain.Port{}), database.ErrClosed)
+
+	geoIndex := database.NewGeoIndex()
+	db = openFileDB(t, path, database.FileDBOptions{}, geoIndex)
+	assert.Equal(t, 1, db.Len())
+	port, err := db.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam Europoort", port.Name)
+	assert.Nil(t, port.Coordinates)
+	_, err = db.Get(ctx, "DEHAM")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+	assert.Empty(t, geoIndex.Nearest(domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}, 1, 0))
+
+	// Writes after a reload land behind the replayed records.
+	require.NoError(t, db.Set(ctx, "BEANR", domain.Port{Key: "BEANR", Name: "Antwerp"}))
+	require.NoError(t, db.Close())
+	db = openFileDB(t, path, database.FileDBOptions{})
+	assert.Equal(t, 2, db.Len())
+}
+
+func TestFileDB_UpsertSkipsUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.log")
+	ctx := context.Background()
+	db := openFileDB(t, path, database.FileDBOptions{})
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Coordinates: &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}}
+
+	outcome, err := db.Upsert(ctx, "NLRTM", rotterdam)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Created, outcome)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	outcome, err = db.Upsert(ctx, "NLRTM", rotterdam)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Unchanged, outcome)
+	unchanged, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), unchanged.Size(), "an unchanged port must not be logged")
+
+	rotterdam.Name = "Rotterdam Europoort"
+	outcome, err = db.Upsert(ctx, "NLRTM", rotterdam)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Updated, outcome)
+}
+
+func TestFileDB_DropsTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.log")
+	ctx := context.Background()
+
+	db := openFileDB(t, path, database.FileDBOptions{})
+	require.NoError(t, db.Set(ctx, "NLRTM", domain.Port{Key: "NLRTM", Name: "Rotterdam"}))
+	require.NoError(t, db.Set(ctx, "DEHAM", domain.Port{Key: "DEHAM", Name: "Hamburg"}))
+	require.NoError(t, db.Close())
+
+	// Simulate a crash halfway through writing the last record.
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	intact := bytes.Index(data, []byte("\n")) + 1
+	require.NoError(t, os.WriteFile(path, data[:len(data)-10], 0o644))
+
+	db = openFileDB(t, path, database.FileDBOptions{})
+	assert.Equal(t, 1, db.Len())
+	_, err = db.Get(ctx, "DEHAM")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(intact), info.Size())
+
+	require.NoError(t, db.Set(ctx, "DEHAM", domain.Port{Key: "DEHAM", Name: "Hamburg"}))
+	require.NoError(t, db.Close())
+	db = openFileDB(t, path, database.FileDBOptions{})
+	assert.Equal(t, 2, db.Len())
+}
+
+func TestFileDB_RejectsCorruptLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.log")
+	ctx := context.Background()
+
+	db := openFileDB(t, path, database.FileDBOptions{})
+	require.NoError(t, db.Set(ctx, "NLRTM", domain.Port{Key: "NLRTM", Name: "Rotterdam"}))
+	require.NoError(t, db.Set(ctx, "DEHAM", domain.Port{Key: "DEHAM", Name: "Hamburg"}))
+	require.NoError(t, db.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, bytes.Replace(data, []byte("Rotterdam"), []byte("Rotterdom"), 1), 0o644))
+
+	_, err = database.OpenFileDB[domain.Port](path, database.FileDBOptions{})
+	assert.ErrorContains(t, err, "corrupt at offset 0")
+}
+
+func TestFileDB_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.log")
+	ctx := context.Background()
+
+	db := openFileDB(t, path, database.FileDBOptions{SyncInterval: time.Millisecond, CompactInterval: 10 * time.Millisecond})
+	for i := 0; i < 50; i++ {
+		require.NoError(t, db.Set(ctx, "NLRTM", domain.Port{Key: "NLRTM", Name: "Rotterdam", Code: string(rune('a' + i%26))}))
+	}
+	require.NoError(t, db.Set(ctx, "DEHAM", domain.Port{Key: "DEHAM", Name: "Hamburg"}))
+
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		after, err := os.Stat(path)
+		return err == nil && after.Size() < before.Size()/10
+	}, time.Second, 5*time.Millisecond)
+
+	// Compact explicitly too, it has to keep accepting writes afterwards.
+	require.NoError(t, db.Compact())
+	require.NoError(t, db.Set(ctx, "BEANR", domain.Port{Key: "BEANR", Name: "Antwerp"}))
+	require.NoError(t, db.Close())
+
+	db = openFileDB(t, path, database.FileDBOptions{})
+	assert.Equal(t, 3, db.Len())
+	port, err := db.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, string(rune('a'+49%26)), port.Code)
+}
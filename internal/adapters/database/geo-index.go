@@ -0,0 +1,209 @@
+package database
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"ports-service/internal/domain"
+)
+
+// geoCellDegrees is the edge length of a GeoIndex grid cell. One degree
+// keeps cells small enough for nearby lookups to touch few ports while the
+// whole grid (180 x 360 cells) stays cheap to walk for far away ones.
+const geoCellDegrees = 1
+
+// geoCell identifies a grid cell by its row (latitude) and column (longitude).
+type geoCell struct {
+	row, col int
+}
+
+const (
+	geoRows = 180 / geoCellDegrees
+	geoCols = 360 / geoCellDegrees
+)
+
+func cellOf(point domain.GeoPoint) geoCell {
+	row := int(math.Floor((point.Lat + 90) / geoCellDegrees))
+	col := int(math.Floor((point.Lon + 180) / geoCellDegrees))
+	// The north pole and the antimeridian belong to the last row and column.
+	return geoCell{row: min(row, geoRows-1), col: ((col % geoCols) + geoCols) % geoCols}
+}
+
+// GeoIndex is a spatial index over the coordinates of stored Ports. It
+// buckets ports into a fixed grid of latitude/longitude cells and answers
+// nearest-port queries by searching rings of cells around the query point,
+// closest first. Register it with NewMemDB to keep it in sync with the
+// stored ports; it implements domain.PortLocator.
+type GeoIndex struct {
+	mu     sync.RWMutex
+	points map[string]domain.GeoPoint
+	cells  map[geoCell]map[string]domain.GeoPoint
+}
+
+// NewGeoIndex returns an empty GeoIndex.
+func NewGeoIndex() *GeoIndex {
+	return &GeoIndex{
+		points: make(map[string]domain.GeoPoint),
+		cells:  make(map[geoCell]map[string]domain.GeoPoint),
+	}
+}
+
+// Put indexes the coordinates of port, replacing those of old. A tombstone
+// is not indexed.
+func (g *GeoIndex) Put(key string, old *domain.Port, port domain.Port) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.remove(key)
+	if port.Coordinates == nil || port.Deleted() {
+		return
+	}
+
+	point := *port.Coordinates
+	cell := cellOf(point)
+	if g.cells[cell] == nil {
+		g.cells[cell] = make(map[string]domain.GeoPoint)
+	}
+	g.cells[cell][key] = point
+	g.points[key] = point
+}
+
+// Remove drops key from the index.
+func (g *GeoIndex) Remove(key string, old domain.Port) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.remove(key)
+}
+
+func (g *GeoIndex) remove(key string) {
+	point, ok := g.points[key]
+	if !ok {
+		return
+	}
+	cell := cellOf(point)
+	delete(g.cells[cell], key)
+	if len(g.cells[cell]) == 0 {
+		delete(g.cells, cell)
+	}
+	delete(g.points, key)
+}
+
+// Nearest returns up to n indexed ports within maxDistanceKm of point,
+// closest first. A maxDistanceKm of zero or less means no limit.
+func (g *GeoIndex) Nearest(point domain.GeoPoint, n int, maxDistanceKm float64) []domain.PortDistance {
+	if n <= 0 {
+		return nil
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	center := cellOf(point)
+	visited := make(map[geoCell]bool)
+	var found []domain.PortDistance
+
+	visit := func(cell geoCell) {
+		if visited[cell] {
+			return
+		}
+		visited[cell] = true
+
+		for key, candidate := range g.cells[cell] {
+			distance := point.DistanceKm(candidate)
+			if maxDistanceKm > 0 && distance > maxDistanceKm {
+				continue
+			}
+			found = append(found, domain.PortDistance{Key: key, DistanceKm: distance})
+		}
+	}
+
+	for ring := 0; ring <= max(geoRows, geoCols/2); ring++ {
+		// Once the rings cover more cells than hold any ports, visiting
+		// the occupied cells directly is cheaper and completes the search.
+		if side := 2*ring + 1; side*side > len(g.cells) {
+			for cell := range g.cells {
+				visit(cell)
+			}
+			break
+		}
+
+		for _, cell := range ringCells(center, ring) {
+			visit(cell)
+		}
+
+		// Every port outside the rings searched so far is at least this
+		// far away, so stop once it cannot improve the result.
+		bound := ringLowerBoundKm(point, ring)
+		if maxDistanceKm > 0 && bound > maxDistanceKm {
+			break
+		}
+		if len(found) >= n {
+			sortByDistance(found)
+			found = found[:n]
+			if bound >= found[n-1].DistanceKm {
+				break
+			}
+		}
+	}
+
+	sortByDistance(found)
+	if len(found) > n {
+		found = found[:n]
+	}
+	return found
+}
+
+// ringCells returns the cells at Chebyshev distance ring from center.
+// Columns wrap around the antimeridian, rows stop at the poles.
+func ringCells(center geoCell, ring int) []geoCell {
+	if ring == 0 {
+		return []geoCell{center}
+	}
+
+	var cells []geoCell
+	add := func(row, col int) {
+		if row < 0 || row >= geoRows {
+			return
+		}
+		cells = append(cells, geoCell{row: row, col: ((col % geoCols) + geoCols) % geoCols})
+	}
+	for d := -ring; d <= ring; d++ {
+		add(center.row-ring, center.col+d)
+		add(center.row+ring, center.col+d)
+	}
+	for d := -ring + 1; d <= ring-1; d++ {
+		add(center.row+d, center.col-ring)
+		add(center.row+d, center.col+ring)
+	}
+	return cells
+}
+
+// ringLowerBoundKm returns a distance from point that every port in a cell
+// beyond the given ring is guaranteed to exceed. Such a cell is at least
+// ring cells away in latitude or in longitude; a longitude gap shrinks
+// towards the poles, so it is measured at the highest latitude the cell can
+// have.
+func ringLowerBoundKm(point domain.GeoPoint, ring int) float64 {
+	gap := float64(ring * geoCellDegrees)
+
+	latBound := gap * math.Pi / 180 * domain.EarthRadiusKm
+
+	if gap >= 180 {
+		return latBound
+	}
+	maxLat := math.Min(90, math.Abs(point.Lat)+gap+geoCellDegrees)
+	lonBound := domain.GeoPoint{Lat: maxLat, Lon: 0}.DistanceKm(domain.GeoPoint{Lat: maxLat, Lon: gap})
+
+	return math.Min(latBound, lonBound)
+}
+
+func sortByDistance(found []domain.PortDistance) {
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].DistanceKm != found[j].DistanceKm {
+			return found[i].DistanceKm < found[j].DistanceKm
+		}
+		return found[i].Key < found[j].Key
+	})
+}
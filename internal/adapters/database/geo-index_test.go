@@ -0,0 +1,131 @@
+package database_test
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/adapters/streamfromfile"
+	"ports-service/internal/domain"
+)
+
+func portAt(key string, lat, lon float64) domain.Port {
+	return domain.Port{Key: key, Coordinates: &domain.GeoPoint{Lat: lat, Lon: lon}}
+}
+
+func keysOf(found []domain.PortDistance) []string {
+	keys := make([]string, 0, len(found))
+	for _, f := range found {
+		keys = append(keys, f.Key)
+	}
+	return keys
+}
+
+func TestGeoIndex_Nearest(t *testing.T) {
+	index := database.NewGeoIndex()
+	db := database.NewMemDB[domain.Port](index)
+	ctx := context.Background()
+
+	for _, port := range []domain.Port{
+		portAt("NLRTM", 51.9225, 4.47917),
+		portAt("NLAMS", 52.3676, 4.90414),
+		portAt("DEHAM", 53.551085, 9.993682),
+		portAt("USNYC", 40.7127837, -74.0059413),
+		{Key: "XXNOC"}, // No coordinates, never found.
+	} {
+		require.NoError(t, db.Set(ctx, port.Key, port))
+	}
+
+	rotterdam := domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}
+
+	found := index.Nearest(rotterdam, 3, 0)
+	assert.Equal(t, []string{"NLRTM", "NLAMS", "DEHAM"}, keysOf(found))
+	assert.InDelta(t, 0, found[0].DistanceKm, 0.001)
+	assert.InDelta(t, 57, found[1].DistanceKm, 1)
+
+	assert.Equal(t, []string{"NLRTM", "NLAMS"}, keysOf(index.Nearest(rotterdam, 10, 100)))
+	assert.Len(t, index.Nearest(rotterdam, 10, 0), 4)
+	assert.Empty(t, index.Nearest(rotterdam, 0, 0))
+
+	// Moving a port moves it in the index.
+	require.NoError(t, db.Set(ctx, "NLAMS", portAt("NLAMS", 40.7, -74)))
+	assert.Equal(t, []string{"NLRTM", "DEHAM"}, keysOf(index.Nearest(rotterdam, 10, 1000)))
+
+	// So does removing its coordinates or the port itself.
+	require.NoError(t, db.Set(ctx, "DEHAM", domain.Port{Key: "DEHAM"}))
+	require.NoError(t, db.Delete(ctx, "NLRTM"))
+	assert.Empty(t, index.Nearest(rotterdam, 10, 1000))
+}
+
+func TestGeoIndex_NearestAcrossAntimeridianAndPole(t *testing.T) {
+	index := database.NewGeoIndex()
+	db := database.NewMemDB[domain.Port](index)
+	ctx := context.Background()
+
+	require.NoError(t, db.Set(ctx, "FJSUV", portAt("FJSUV", -18.1416, 178.4419)))
+	require.NoError(t, db.Set(ctx, "WSAPW", portAt("WSAPW", -13.8333, -171.75)))
+	require.NoError(t, db.Set(ctx, "NORLB", portAt("NORLB", 89.5, 10)))
+	require.NoError(t, db.Set(ctx, "CAALE", portAt("CAALE", 82.5, -62.3)))
+
+	found := index.Nearest(domain.GeoPoint{Lat: -16, Lon: -179.9}, 1, 0)
+	assert.Equal(t, []string{"FJSUV"}, keysOf(found))
+
+	found = index.Nearest(domain.GeoPoint{Lat: 89.9, Lon: -170}, 2, 0)
+	assert.Equal(t, []string{"NORLB", "CAALE"}, keysOf(found))
+}
+
+// TestGeoIndex_MatchesBruteForce compares the index against computing the
+// distance to every port in the bundled ports file.
+func TestGeoIndex_MatchesBruteForce(t *testing.T) {
+	index := database.NewGeoIndex()
+	db := database.NewMemDB[domain.Port](index)
+	ctx := context.Background()
+
+	var all []domain.Port
+	ch, err := streamfromfile.NewFileStreamer[domain.Port]("../../../data/ports.json").StreamObjects(ctx, 100)
+	require.NoError(t, err)
+	for port := range ch {
+		require.NoError(t, db.Set(ctx, port.Key, port))
+		if port.Coordinates != nil {
+			all = append(all, port)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		point := domain.GeoPoint{Lat: rng.Float64()*180 - 90, Lon: rng.Float64()*360 - 180}
+		n := 1 + rng.Intn(20)
+		maxDistanceKm := 0.0
+		if i%2 == 0 {
+			maxDistanceKm = rng.Float64() * 3000
+		}
+
+		var want []domain.PortDistance
+		for _, port := range all {
+			distance := point.DistanceKm(*port.Coordinates)
+			if maxDistanceKm > 0 && distance > maxDistanceKm {
+				continue
+			}
+			want = append(want, domain.PortDistance{Key: port.Key, DistanceKm: distance})
+		}
+		sort.Slice(want, func(i, j int) bool {
+			if want[i].DistanceKm != want[j].DistanceKm {
+				return want[i].DistanceKm < want[j].DistanceKm
+			}
+			return want[i].Key < want[j].Key
+		})
+		if len(want) > n {
+			want = want[:n]
+		}
+
+		got := index.Nearest(point, n, maxDistanceKm)
+		if !assert.Equal(t, keysOf(want), keysOf(got), "point %+v, n %d, max %v", point, n, maxDistanceKm) {
+			return
+		}
+	}
+}
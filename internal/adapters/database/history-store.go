@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"ports-service/internal/domain"
+	"ports-service/internal/ports"
+)
+
+// HistoryStore keeps the revisions of every Port as a single entry per key
+// in a ports.Store, like a MemDB or, to keep them across restarts, a FileDB.
+// It implements domain.PortHistoryStore.
+type HistoryStore struct {
+	store ports.Store[[]domain.PortRevision]
+}
+
+// NewHistoryStore returns a HistoryStore keeping the revisions in store.
+func NewHistoryStore(store ports.Store[[]domain.PortRevision]) *HistoryStore {
+	return &HistoryStore{store: store}
+}
+
+// Append adds revision to the history of the Port under key, replacing the
+// revisions from the same one on. Those were recorded for a write that then
+// failed to be stored.
+func (h *HistoryStore) Append(ctx context.Context, key string, revision domain.PortRevision) error {
+	_, err := h.store.Update(ctx, key, func(old *[]domain.PortRevision) ([]domain.PortRevision, error) {
+		if old == nil {
+			return []domain.PortRevision{revision}, nil
+		}
+		kept := *old
+		for len(kept) > 0 && kept[len(kept)-1].Revision >= revision.Revision {
+			kept = kept[:len(kept)-1]
+		}
+		// Copy, so the stored slice is never appended to in place.
+		return append(kept[:len(kept):len(kept)], revision), nil
+	})
+	return err
+}
+
+// History returns the revisions of the Port under key, oldest first.
+func (h *HistoryStore) History(ctx context.Context, key string) ([]domain.PortRevision, error) {
+	revisions, err := h.store.Get(ctx, key)
+	if errors.Is(err, ports.ErrNotFound) {
+		return nil, nil
+	}
+	return revisions, err
+}
@@ -0,0 +1,61 @@
+package database_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/domain"
+)
+
+func TestHistoryStore_ReloadsOnOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports-history.log")
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Revision: 1, UpdatedAt: &at}
+
+	db, err := database.OpenFileDB[[]domain.PortRevision](path, database.FileDBOptions{})
+	require.NoError(t, err)
+	history := database.NewHistoryStore(db)
+	revisions, err := history.History(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+
+	require.NoError(t, history.Append(ctx, "NLRTM", domain.PortRevision{Revision: 1, Time: at, Port: &rotterdam}))
+	require.NoError(t, history.Append(ctx, "NLRTM", domain.PortRevision{Revision: 2, Time: at.Add(time.Hour)}))
+	require.NoError(t, db.Close())
+
+	db, err = database.OpenFileDB[[]domain.PortRevision](path, database.FileDBOptions{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	revisions, err = database.NewHistoryStore(db).History(ctx, "NLRTM")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, uint64(1), revisions[0].Revision)
+	assert.True(t, at.Equal(revisions[0].Time))
+	require.NotNil(t, revisions[0].Port)
+	assert.Equal(t, "Rotterdam", revisions[0].Port.Name)
+	assert.Equal(t, uint64(2), revisions[1].Revision)
+	assert.Nil(t, revisions[1].Port)
+}
+
+func TestHistoryStore_AppendReplacesLaterRevisions(t *testing.T) {
+	ctx := context.Background()
+	history := database.NewHistoryStore(database.NewMemDB[[]domain.PortRevision]())
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Revision 2 was recorded for a write that failed, its retry replaces it.
+	require.NoError(t, history.Append(ctx, "NLRTM", domain.PortRevision{Revision: 1, Time: at}))
+	require.NoError(t, history.Append(ctx, "NLRTM", domain.PortRevision{Revision: 2, Time: at.Add(time.Hour)}))
+	require.NoError(t, history.Append(ctx, "NLRTM", domain.PortRevision{Revision: 2, Time: at.Add(2 * time.Hour)}))
+
+	revisions, err := history.History(ctx, "NLRTM")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, at.Add(2*time.Hour), revisions[1].Time)
+}
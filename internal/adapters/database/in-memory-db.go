@@ -2,18 +2,198 @@ package database
 
 import (
 	"context"
+	"reflect"
+	"sort"
+	"sync"
+
+	"ports-service/internal/ports"
 )
 
+// DefaultPageSize is used by List when the caller does not ask for a page size.
+const DefaultPageSize = 100
+
 // MemDB represents a simplistic in-memory database abstraction in Go.
 // It's a generic type, allowing it to store any type of value.
 // The database is represented as a map, with string keys and generic type values.
+// A MemDB is safe for concurrent use; create one with NewMemDB.
 type MemDB[T any] struct {
-	// TODO: Add mutex
-	DB map[string]T // Map acting as the in-memory storage.
+	mu      sync.RWMutex
+	db      map[string]T // Map acting as the in-memory storage.
+	indexes []Index[T]   // Kept up to date with db on every write.
+}
+
+// Index is a secondary lookup structure a MemDB maintains alongside its
+// values. Its methods are called with the MemDB write lock held, so an index
+// never misses or reorders a write; it still needs its own locking for reads.
+type Index[T any] interface {
+	// Put is called after key has been set to value. old is the value it
+	// replaced, or nil if key was new.
+	Put(key string, old *T, value T)
+	// Remove is called after key, holding old, has been deleted.
+	Remove(key string, old T)
+}
+
+// NewMemDB returns an empty MemDB ready for use, maintaining the given
+// indexes on every write.
+func NewMemDB[T any](indexes ...Index[T]) *MemDB[T] {
+	return &MemDB[T]{db: make(map[string]T), indexes: indexes}
 }
 
 // Set adds or updates a value in the in-memory database.
 func (db *MemDB[T]) Set(ctx context.Context, key string, value T) error {
-	db.DB[key] = value // Store or update the value in the map.
-	return nil         // In this simple implementation, no error handling is performed.
+	_, err := db.Upsert(ctx, key, value)
+	return err
+}
+
+// Upsert adds or updates a value in the in-memory database, leaving it and
+// the indexes alone if an equal value is already stored.
+func (db *MemDB[T]) Upsert(ctx context.Context, key string, value T) (ports.UpsertOutcome, error) {
+	return db.Update(ctx, key, func(*T) (T, error) { return value, nil })
+}
+
+// Update stores the value fn derives from the one stored for key, see
+// ports.Store. fn is called with the write lock held, so it must not use db.
+func (db *MemDB[T]) Update(ctx context.Context, key string, fn func(old *T) (T, error)) (ports.UpsertOutcome, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	old, existed := db.db[key]
+	var value T
+	var err error
+	if existed {
+		value, err = fn(&old)
+	} else {
+		value, err = fn(nil)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if existed && equalValues(old, value) {
+		return ports.Unchanged, nil
+	}
+	db.db[key] = value // Store or update the value in the map.
+
+	for _, index := range db.indexes {
+		if existed {
+			index.Put(key, &old, value)
+		} else {
+			index.Put(key, nil, value)
+		}
+	}
+	if existed {
+		return ports.Updated, nil
+	}
+	return ports.Created, nil
+}
+
+// equalValues reports whether a and b are equal, using the Equal method of
+// T if it has one, like domain.Port, and reflect.DeepEqual otherwise.
+func equalValues[T any](a, b T) bool {
+	if equaler, ok := any(a).(interface{ Equal(T) bool }); ok {
+		return equaler.Equal(b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// isDeleted reports whether value is a tombstone, using the Deleted method
+// of T if it has one, like domain.Port.
+func isDeleted[T any](value T) bool {
+	deleter, ok := any(value).(interface{ Deleted() bool })
+	return ok && deleter.Deleted()
+}
+
+// Get returns the value stored for key.
+func (db *MemDB[T]) Get(ctx context.Context, key string) (T, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	value, ok := db.db[key]
+	if !ok {
+		var zero T
+		return zero, &ports.NotFoundError{Key: key}
+	}
+	return value, nil
+}
+
+// Delete removes the value stored for key.
+func (db *MemDB[T]) Delete(ctx context.Context, key string) error {
+	_, err := db.DeleteIf(ctx, key, func(T) bool { return true })
+	return err
+}
+
+// DeleteIf removes the value stored for key if cond returns true for it.
+// cond is called with the write lock held, so it must not use db.
+func (db *MemDB[T]) DeleteIf(ctx context.Context, key string, cond func(T) bool) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	old, ok := db.db[key]
+	if !ok {
+		return false, &ports.NotFoundError{Key: key}
+	}
+	if !cond(old) {
+		return false, nil
+	}
+	delete(db.db, key)
+
+	for _, index := range db.indexes {
+		index.Remove(key, old)
+	}
+	return true, nil
+}
+
+// keys returns the stored keys, in no particular order.
+func (db *MemDB[T]) keys() []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	keys := make([]string, 0, len(db.db))
+	for key := range db.db {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// List returns values in key order. The page token is the last key of the
+// previous page, so pagination stays stable while other keys are written.
+func (db *MemDB[T]) List(ctx context.Context, opts ports.ListOptions[T]) (ports.Page[T], error) {
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	keys := make([]string, 0, len(db.db))
+	for key := range db.db {
+		if key > opts.PageToken {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var page ports.Page[T]
+	for i, key := range keys {
+		value := db.db[key]
+		if opts.Filter != nil && !opts.Filter(value) {
+			continue
+		}
+		if len(page.Items) == pageSize {
+			// At least one more match exists, so hand out a token
+			// pointing just past the last returned key.
+			page.NextPageToken = keys[i-1]
+			break
+		}
+		page.Items = append(page.Items, value)
+	}
+	return page, nil
+}
+
+// Len returns the number of stored values.
+func (db *MemDB[T]) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return len(db.db)
 }
@@ -2,38 +2,52 @@ package database_test
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
 
 	"ports-service/internal/adapters/database"
+	"ports-service/internal/adapters/streamfromfile"
+	"ports-service/internal/domain"
+	"ports-service/internal/ports"
 
 	"github.com/stretchr/testify/assert"
 )
 
+func newMemDB(t *testing.T, seed map[string]string) *database.MemDB[string] {
+	t.Helper()
+	memDB := database.NewMemDB[string]()
+	for key, value := range seed {
+		assert.NoError(t, memDB.Set(context.Background(), key, value))
+	}
+	return memDB
+}
+
 func TestSet(t *testing.T) {
 	testCases := []struct {
 		name    string
-		memDB   database.MemDB[string]
+		memDB   *database.MemDB[string]
 		key     string
 		value   string
 		wantErr bool
 	}{
 		{
 			name:    "NewValue",
-			memDB:   database.MemDB[string]{DB: make(map[string]string)},
+			memDB:   database.NewMemDB[string](),
 			key:     "newKey",
 			value:   "newValue",
 			wantErr: false,
 		},
 		{
 			name:    "UpdateValue",
-			memDB:   database.MemDB[string]{DB: make(map[string]string)},
+			memDB:   newMemDB(t, map[string]string{"existingKey": "oldValue"}),
 			key:     "existingKey",
 			value:   "updatedValue",
 			wantErr: false,
 		},
 		{
 			name:    "BooleanValue",
-			memDB:   database.MemDB[string]{DB: make(map[string]string)},
+			memDB:   database.NewMemDB[string](),
 			key:     "boolKey",
 			value:   "true",
 			wantErr: false,
@@ -47,8 +61,227 @@ func TestSet(t *testing.T) {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tc.value, tc.memDB.DB[tc.key])
+				value, err := tc.memDB.Get(context.Background(), tc.key)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.value, value)
 			}
 		})
 	}
 }
+
+func TestMemDB_Upsert(t *testing.T) {
+	ctx := context.Background()
+	events := database.NewEventLog(0)
+	db := database.NewMemDB[domain.Port](events)
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Alias: []string{}}
+
+	outcome, err := db.Upsert(ctx, "NLRTM", rotterdam)
+	assert.NoError(t, err)
+	assert.Equal(t, ports.Created, outcome)
+
+	// An equal port is not written, so indexes see no change. Ports compare
+	// nil and empty lists alike.
+	token := events.ResumeToken()
+	outcome, err = db.Upsert(ctx, "NLRTM", domain.Port{Key: "NLRTM", Name: "Rotterdam"})
+	assert.NoError(t, err)
+	assert.Equal(t, ports.Unchanged, outcome)
+	assert.Equal(t, token, events.ResumeToken())
+
+	outcome, err = db.Upsert(ctx, "NLRTM", domain.Port{Key: "NLRTM", Name: "Rotterdam Europoort"})
+	assert.NoError(t, err)
+	assert.Equal(t, ports.Updated, outcome)
+	assert.NotEqual(t, token, events.ResumeToken())
+
+	// Values without an Equal method are compared deeply.
+	strings := database.NewMemDB[[]string]()
+	_, err = strings.Upsert(ctx, "a", []string{"x"})
+	assert.NoError(t, err)
+	outcome, err = strings.Upsert(ctx, "a", []string{"x"})
+	assert.NoError(t, err)
+	assert.Equal(t, ports.Unchanged, outcome)
+}
+
+func TestMemDB_Update(t *testing.T) {
+	ctx := context.Background()
+	db := newMemDB(t, map[string]string{"a": "x"})
+	appendY := func(old *string) (string, error) {
+		if old == nil {
+			return "y", nil
+		}
+		return *old + "y", nil
+	}
+
+	outcome, err := db.Update(ctx, "a", appendY)
+	assert.NoError(t, err)
+	assert.Equal(t, ports.Updated, outcome)
+	outcome, err = db.Update(ctx, "b", appendY)
+	assert.NoError(t, err)
+	assert.Equal(t, ports.Created, outcome)
+
+	// An error of fn is returned as is and nothing is stored.
+	failed := errors.New("failed")
+	_, err = db.Update(ctx, "a", func(*string) (string, error) { return "z", failed })
+	assert.Same(t, failed, err)
+	value, err := db.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "xy", value)
+}
+
+func TestGet(t *testing.T) {
+	memDB := newMemDB(t, map[string]string{"existingKey": "value"})
+
+	value, err := memDB.Get(context.Background(), "existingKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	_, err = memDB.Get(context.Background(), "missingKey")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+
+	var notFound *ports.NotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "missingKey", notFound.Key)
+}
+
+func TestDelete(t *testing.T) {
+	memDB := newMemDB(t, map[string]string{"existingKey": "value"})
+
+	assert.NoError(t, memDB.Delete(context.Background(), "existingKey"))
+	assert.Equal(t, 0, memDB.Len())
+
+	err := memDB.Delete(context.Background(), "existingKey")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+}
+
+func TestMemDB_DeleteIf(t *testing.T) {
+	memDB := newMemDB(t, map[string]string{"keep": "live", "drop": "expired"})
+	expired := func(value string) bool { return value == "expired" }
+
+	deleted, err := memDB.DeleteIf(context.Background(), "keep", expired)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	deleted, err = memDB.DeleteIf(context.Background(), "drop", expired)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, 1, memDB.Len())
+
+	_, err = memDB.DeleteIf(context.Background(), "drop", expired)
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+}
+
+func TestList(t *testing.T) {
+	memDB := newMemDB(t, map[string]string{
+		"a": "1", "b": "2", "c": "3", "d": "4", "e": "5",
+	})
+	ctx := context.Background()
+
+	testCases := []struct {
+		name      string
+		opts      ports.ListOptions[string]
+		wantItems []string
+		wantToken string
+	}{
+		{
+			name:      "FirstPage",
+			opts:      ports.ListOptions[string]{PageSize: 2},
+			wantItems: []string{"1", "2"},
+			wantToken: "b",
+		},
+		{
+			name:      "NextPage",
+			opts:      ports.ListOptions[string]{PageToken: "b", PageSize: 2},
+			wantItems: []string{"3", "4"},
+			wantToken: "d",
+		},
+		{
+			name:      "LastPage",
+			opts:      ports.ListOptions[string]{PageToken: "d", PageSize: 2},
+			wantItems: []string{"5"},
+		},
+		{
+			name:      "ExactlyFullPage",
+			opts:      ports.ListOptions[string]{PageToken: "c", PageSize: 2},
+			wantItems: []string{"4", "5"},
+		},
+		{
+			name:      "DefaultPageSize",
+			opts:      ports.ListOptions[string]{},
+			wantItems: []string{"1", "2", "3", "4", "5"},
+		},
+		{
+			name: "Filtered",
+			opts: ports.ListOptions[string]{
+				PageSize: 1,
+				Filter:   func(v string) bool { return v == "2" || v == "5" },
+			},
+			wantItems: []string{"2"},
+			wantToken: "d",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := memDB.List(ctx, tc.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantItems, page.Items)
+			assert.Equal(t, tc.wantToken, page.NextPageToken)
+		})
+	}
+}
+
+// TestMemDB_ConcurrentIngest streams the bundled ports file into a MemDB
+// from several writers while readers look keys up and page through the
+// data. Run it with -race to catch unsynchronised map access.
+func TestMemDB_ConcurrentIngest(t *testing.T) {
+	const (
+		writers = 4
+		readers = 4
+	)
+
+	memDB := database.NewMemDB[domain.Port]()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var writeWG sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		writeWG.Add(1)
+		go func() {
+			defer writeWG.Done()
+			streamer := streamfromfile.NewFileStreamer[domain.Port]("../../../data/ports.json")
+			ch, err := streamer.StreamObjects(ctx, 100)
+			if !assert.NoError(t, err) {
+				return
+			}
+			for port := range ch {
+				assert.NoError(t, memDB.Set(ctx, port.Key, port))
+			}
+		}()
+	}
+
+	var readWG sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		readWG.Add(1)
+		go func() {
+			defer readWG.Done()
+			for i := 0; ctx.Err() == nil; i++ {
+				if port, err := memDB.Get(ctx, "NLRTM"); err == nil {
+					assert.Equal(t, "NLRTM", port.Key)
+				} else {
+					assert.ErrorIs(t, err, ports.ErrNotFound)
+				}
+				if i%100 == 0 {
+					_, err := memDB.List(ctx, ports.ListOptions[domain.Port]{PageToken: "NL", PageSize: 10})
+					assert.NoError(t, err)
+				}
+			}
+		}()
+	}
+
+	writeWG.Wait()
+	cancel()
+	readWG.Wait()
+
+	assert.Equal(t, 1632, memDB.Len())
+	port, err := memDB.Get(context.Background(), "NLRTM")
+	assert.NoError(t, err)
+	assert.Equal(t, "Rotterdam", port.Name)
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"sort"
+	"sync"
+
+	"ports-service/internal/domain"
+)
+
+// SearchIndex is a full-text index over the domain.SearchFields of stored
+// Ports. It keeps an inverted index from every token to the Ports carrying
+// it; a query token is matched against the vocabulary of the index, so only
+// Ports with a matching token are scored. Register it with NewMemDB to keep
+// it in sync with the stored ports; it implements domain.PortSearcher.
+type SearchIndex struct {
+	mu       sync.RWMutex
+	tokens   map[string][][]string          // Key to its domain.PortSearchTokens.
+	postings map[string]map[string]struct{} // Token to the keys carrying it.
+}
+
+// NewSearchIndex returns an empty SearchIndex.
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		tokens:   make(map[string][][]string),
+		postings: make(map[string]map[string]struct{}),
+	}
+}
+
+// Put indexes the searchable fields of port, replacing those of old. A
+// tombstone is not indexed.
+func (s *SearchIndex) Put(key string, old *domain.Port, port domain.Port) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(key)
+	if port.Deleted() {
+		return
+	}
+
+	tokens := domain.PortSearchTokens(port)
+	s.tokens[key] = tokens
+	for _, fieldTokens := range tokens {
+		for _, token := range fieldTokens {
+			if s.postings[token] == nil {
+				s.postings[token] = make(map[string]struct{})
+			}
+			s.postings[token][key] = struct{}{}
+		}
+	}
+}
+
+// Remove drops key from the index.
+func (s *SearchIndex) Remove(key string, old domain.Port) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(key)
+}
+
+func (s *SearchIndex) remove(key string) {
+	for _, fieldTokens := range s.tokens[key] {
+		for _, token := range fieldTokens {
+			delete(s.postings[token], key)
+			if len(s.postings[token]) == 0 {
+				delete(s.postings, token)
+			}
+		}
+	}
+	delete(s.tokens, key)
+}
+
+// Search returns up to n Ports matching every query token, ranked by
+// domain.ScoreMatch, best first. Ties are ordered by key.
+func (s *SearchIndex) Search(query []string, n int) []domain.PortScore {
+	if len(query) == 0 || n <= 0 {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Only Ports with a token matching every query token can score.
+	var candidates map[string]struct{}
+	for _, q := range query {
+		matching := make(map[string]struct{})
+		for token, keys := range s.postings {
+			if domain.MatchToken(q, token) == 0 {
+				continue
+			}
+			for key := range keys {
+				if _, ok := candidates[key]; candidates == nil || ok {
+					matching[key] = struct{}{}
+				}
+			}
+		}
+		candidates = matching
+		if len(candidates) == 0 {
+			return nil
+		}
+	}
+
+	found := make([]domain.PortScore, 0, len(candidates))
+	for key := range candidates {
+		if score := domain.ScoreMatch(query, s.tokens[key]); score > 0 {
+			found = append(found, domain.PortScore{Key: key, Score: score})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Score != found[j].Score {
+			return found[i].Score > found[j].Score
+		}
+		return found[i].Key < found[j].Key
+	})
+	if len(found) > n {
+		found = found[:n]
+	}
+	return found
+}
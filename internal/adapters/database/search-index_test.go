@@ -0,0 +1,80 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/adapters/streamfromfile"
+	"ports-service/internal/domain"
+)
+
+func scoreKeys(found []domain.PortScore) []string {
+	keys := make([]string, 0, len(found))
+	for _, f := range found {
+		keys = append(keys, f.Key)
+	}
+	return keys
+}
+
+func TestSearchIndex_Overwrite(t *testing.T) {
+	index := database.NewSearchIndex()
+	db := database.NewMemDB[domain.Port](index)
+	ctx := context.Background()
+
+	require.NoError(t, db.Set(ctx, "NLRTM", domain.Port{Key: "NLRTM", Name: "Rotterdam"}))
+	assert.Equal(t, []string{"NLRTM"}, scoreKeys(index.Search([]string{"rotterdam"}, 10)))
+
+	require.NoError(t, db.Set(ctx, "NLRTM", domain.Port{Key: "NLRTM", Name: "Europoort"}))
+	assert.Empty(t, index.Search([]string{"rotterdam"}, 10))
+	assert.Equal(t, []string{"NLRTM"}, scoreKeys(index.Search([]string{"europoort"}, 10)))
+
+	require.NoError(t, db.Delete(ctx, "NLRTM"))
+	assert.Empty(t, index.Search([]string{"europoort"}, 10))
+}
+
+// TestSearchIndex_BundledPorts searches the bundled ports file the way
+// operators do and checks the index ranks like scanning every port.
+func TestSearchIndex_BundledPorts(t *testing.T) {
+	index := database.NewSearchIndex()
+	db := database.NewMemDB[domain.Port](index)
+	ctx := context.Background()
+
+	ch, err := streamfromfile.NewFileStreamer[domain.Port]("../../../data/ports.json").StreamObjects(ctx, 100)
+	require.NoError(t, err)
+	for port := range ch {
+		require.NoError(t, db.Set(ctx, port.Key, port))
+	}
+	indexed := domain.StorePortRepository{Data: db, Searcher: index}
+	scanning := domain.StorePortRepository{Data: db}
+
+	testCases := []struct {
+		query string
+		first string
+	}{
+		{query: "Rotterdam", first: "NLRTM"},
+		{query: "rotterdm", first: "NLRTM"},
+		{query: "roterdam", first: "NLRTM"},
+		{query: "rotter", first: "NLRTM"},
+		{query: "Abu Zaby", first: "AEAUH"},
+		{query: "abu dabi", first: "AEAUH"},
+		{query: "dusseldorf", first: "DEDUS"},
+		{query: "bodo", first: "NOBOO"},
+		{query: "Ciudad de Mexico", first: "MXMEX"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := indexed.SearchPorts(ctx, tc.query, 5)
+			require.NoError(t, err)
+			require.NotEmpty(t, got)
+			assert.Equal(t, tc.first, got[0].Port.Key)
+
+			want, err := scanning.SearchPorts(ctx, tc.query, 5)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
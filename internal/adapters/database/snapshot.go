@@ -0,0 +1,375 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ports-service/internal/ports"
+)
+
+// SnapshotFormat is the file format of a snapshot.
+type SnapshotFormat string
+
+const (
+	// SnapshotJSON is a single JSON object mapping every key to its value,
+	// the format of data/ports.json.
+	SnapshotJSON SnapshotFormat = "json"
+	// SnapshotNDJSON is one {"key": ..., "value": ...} object per line.
+	SnapshotNDJSON SnapshotFormat = "ndjson"
+)
+
+// ParseSnapshotFormat returns the SnapshotFormat named s.
+func ParseSnapshotFormat(s string) (SnapshotFormat, error) {
+	switch format := SnapshotFormat(s); format {
+	case SnapshotJSON, SnapshotNDJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown snapshot format %q, use %s or %s", s, SnapshotJSON, SnapshotNDJSON)
+	}
+}
+
+// snapshotEntry is a line of an SnapshotNDJSON snapshot.
+type snapshotEntry[T any] struct {
+	Key   string `json:"key"`
+	Value T      `json:"value"`
+}
+
+// Snapshot writes every stored value to w, in key order. The values are
+// copied first, so writes are only blocked while copying.
+func (db *MemDB[T]) Snapshot(ctx context.Context, w io.Writer, format SnapshotFormat) (int, error) {
+	db.mu.RLock()
+	entries := make([]snapshotEntry[T], 0, len(db.db))
+	for key, value := range db.db {
+		entries = append(entries, snapshotEntry[T]{Key: key, Value: value})
+	}
+	db.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return encodeSnapshot(ctx, w, format, entries)
+}
+
+// Restore replaces the contents of db with the snapshot read from r: keys
+// missing from the snapshot are deleted. Nothing is changed if the snapshot
+// cannot be read.
+func (db *MemDB[T]) Restore(ctx context.Context, r io.Reader, format SnapshotFormat) (int, error) {
+	return restoreAll[T](ctx, db, db.keys(), r, format)
+}
+
+// Snapshot writes every stored value to w, see MemDB.Snapshot.
+func (db *FileDB[T]) Snapshot(ctx context.Context, w io.Writer, format SnapshotFormat) (int, error) {
+	return db.mem.Snapshot(ctx, w, format)
+}
+
+// Restore replaces the contents of db with the snapshot read from r, see
+// MemDB.Restore, logging every change like Set and Delete do.
+func (db *FileDB[T]) Restore(ctx context.Context, r io.Reader, format SnapshotFormat) (int, error) {
+	return restoreAll[T](ctx, db, db.mem.keys(), r, format)
+}
+
+// restoreAll replaces the contents of store, holding the stored keys, with
+// the snapshot read from r once the whole snapshot has been read.
+func restoreAll[T any](ctx context.Context, store ports.Store[T], stored []string, r io.Reader, format SnapshotFormat) (int, error) {
+	entries := make(map[string]T)
+	_, err := decodeSnapshot(ctx, r, format, func(_ context.Context, key string, value T) error {
+		entries[key] = value
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, key := range stored {
+		if _, ok := entries[key]; ok {
+			continue
+		}
+		if err := store.Delete(ctx, key); err != nil && !errors.Is(err, ports.ErrNotFound) {
+			return 0, fmt.Errorf("delete %s: %w", key, err)
+		}
+	}
+	for key, value := range entries {
+		if err := store.Set(ctx, key, value); err != nil {
+			return 0, fmt.Errorf("restore %s: %w", key, err)
+		}
+	}
+	return len(entries), nil
+}
+
+func encodeSnapshot[T any](ctx context.Context, w io.Writer, format SnapshotFormat, entries []snapshotEntry[T]) (int, error) {
+	buf := bufio.NewWriter(w)
+
+	if format == SnapshotJSON {
+		if _, err := buf.WriteString("{"); err != nil {
+			return 0, err
+		}
+	}
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+
+		var err error
+		switch format {
+		case SnapshotJSON:
+			err = writeKeyedValue(buf, i, entry)
+		case SnapshotNDJSON:
+			err = json.NewEncoder(buf).Encode(entry)
+		default:
+			err = fmt.Errorf("unknown snapshot format %q", format)
+		}
+		if err != nil {
+			return i, fmt.Errorf("write snapshot entry %s: %w", entry.Key, err)
+		}
+	}
+	if format == SnapshotJSON {
+		if _, err := buf.WriteString("\n}\n"); err != nil {
+			return len(entries), err
+		}
+	}
+	return len(entries), buf.Flush()
+}
+
+// writeKeyedValue writes entry as the i-th member of a JSON object, indented
+// like data/ports.json.
+func writeKeyedValue[T any](w *bufio.Writer, i int, entry snapshotEntry[T]) error {
+	key, err := json.Marshal(entry.Key)
+	if err != nil {
+		return err
+	}
+	value, err := json.MarshalIndent(entry.Value, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	if i > 0 {
+		_ = w.WriteByte(',')
+	}
+	_, _ = w.WriteString("\n  ")
+	_, _ = w.Write(key)
+	_, _ = w.WriteString(": ")
+	_, err = w.Write(value)
+	return err
+}
+
+func decodeSnapshot[T any](ctx context.Context, r io.Reader, format SnapshotFormat, set func(context.Context, string, T) error) (int, error) {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	restored := 0
+
+	switch format {
+	case SnapshotJSON:
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, fmt.Errorf("read snapshot: %w", err)
+		}
+		if token != json.Delim('{') {
+			return 0, fmt.Errorf("read snapshot: expected a JSON object, got %v", token)
+		}
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return restored, fmt.Errorf("read snapshot: %w", err)
+			}
+			key, _ := token.(string)
+			var value T
+			if err := decoder.Decode(&value); err != nil {
+				return restored, fmt.Errorf("read snapshot entry %s: %w", key, err)
+			}
+			// Values in data/ports.json lack their key, fill it in.
+			ports.SetKey(&value, key)
+			if err := set(ctx, key, value); err != nil {
+				return restored, fmt.Errorf("restore %s: %w", key, err)
+			}
+			restored++
+		}
+		return restored, nil
+	case SnapshotNDJSON:
+		for {
+			var entry snapshotEntry[T]
+			err := decoder.Decode(&entry)
+			if err == io.EOF {
+				return restored, nil
+			}
+			if err != nil {
+				return restored, fmt.Errorf("read snapshot entry %d: %w", restored+1, err)
+			}
+			if err := set(ctx, entry.Key, entry.Value); err != nil {
+				return restored, fmt.Errorf("restore %s: %w", entry.Key, err)
+			}
+			restored++
+		}
+	default:
+		return 0, fmt.Errorf("unknown snapshot format %q", format)
+	}
+}
+
+// Snapshotter is a store that can be saved to and restored from snapshots,
+// like MemDB and FileDB.
+type Snapshotter interface {
+	Snapshot(ctx context.Context, w io.Writer, format SnapshotFormat) (int, error)
+	Restore(ctx context.Context, r io.Reader, format SnapshotFormat) (int, error)
+}
+
+// Checkpointer is a store keeping a log of its writes that can be cut short
+// once a snapshot captures them, like WALStore.
+type Checkpointer interface {
+	// LastSeq returns the position of the last write in the log.
+	LastSeq() uint64
+	// Checkpoint records that the writes up to seq are captured in a snapshot.
+	Checkpoint(seq uint64)
+}
+
+// snapshotPrefix starts the name of every file a SnapshotDir manages. The
+// name goes on with a UTC timestamp, so names sort by age.
+const snapshotPrefix = "snapshot-"
+
+// SnapshotDir keeps the snapshots of a store as timestamped files in a
+// directory, pruning all but the newest.
+type SnapshotDir struct {
+	Dir    string
+	Format SnapshotFormat // Format new snapshots are written in.
+	Keep   int            // Snapshots kept when saving another, at least one.
+}
+
+// Save writes a snapshot of store to a new file and prunes old ones. The file
+// only appears under its final name once it has been fully written and
+// synced, so a crash never leaves a truncated snapshot behind. If store is
+// a Checkpointer, it is checkpointed once the snapshot is saved.
+func (d SnapshotDir) Save(ctx context.Context, store Snapshotter) (string, int, error) {
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return "", 0, fmt.Errorf("create snapshot dir: %w", err)
+	}
+
+	// Every write up to here is applied before the snapshot copies the store.
+	checkpointer, checkpoints := store.(Checkpointer)
+	var seq uint64
+	if checkpoints {
+		seq = checkpointer.LastSeq()
+	}
+
+	name := snapshotPrefix + time.Now().UTC().Format("20060102T150405.000000000Z") + "." + string(d.Format)
+	path := filepath.Join(d.Dir, name)
+	tmp, err := os.CreateTemp(d.Dir, ".tmp-"+name)
+	if err != nil {
+		return "", 0, fmt.Errorf("create snapshot: %w", err)
+	}
+
+	n, err := store.Snapshot(ctx, tmp, d.Format)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", 0, fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := syncDir(d.Dir); err != nil {
+		log.Printf("Error syncing snapshot dir %s: %v", d.Dir, err)
+	}
+
+	if checkpoints {
+		checkpointer.Checkpoint(seq)
+	}
+	d.prune()
+	return path, n, nil
+}
+
+// snapshots returns the snapshot files in the directory, oldest first.
+func (d SnapshotDir) snapshots() ([]string, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, snapshotPrefix) &&
+			(filepath.Ext(name) == "."+string(SnapshotJSON) || filepath.Ext(name) == "."+string(SnapshotNDJSON)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d SnapshotDir) prune() {
+	names, err := d.snapshots()
+	if err != nil {
+		log.Printf("Error listing snapshots in %s: %v", d.Dir, err)
+		return
+	}
+	for len(names) > max(d.Keep, 1) {
+		if err := os.Remove(filepath.Join(d.Dir, names[0])); err != nil {
+			log.Printf("Error removing old snapshot: %v", err)
+		}
+		names = names[1:]
+	}
+}
+
+// IsEmpty reports whether store holds no values at all.
+func IsEmpty[T any](ctx context.Context, store ports.Store[T]) (bool, error) {
+	page, err := store.List(ctx, ports.ListOptions[T]{PageSize: 1})
+	if err != nil {
+		return false, err
+	}
+	return len(page.Items) == 0, nil
+}
+
+// RestoreLatest restores store from the newest snapshot in the directory,
+// whatever its format, replacing its contents. It returns an empty path if
+// there is none.
+func (d SnapshotDir) RestoreLatest(ctx context.Context, store Snapshotter) (string, int, error) {
+	names, err := d.snapshots()
+	if os.IsNotExist(err) || (err == nil && len(names) == 0) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("list snapshots: %w", err)
+	}
+
+	path := filepath.Join(d.Dir, names[len(names)-1])
+	file, err := os.Open(path)
+	if err != nil {
+		return path, 0, fmt.Errorf("open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	format := SnapshotFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	n, err := store.Restore(ctx, file, format)
+	if err != nil {
+		return path, n, fmt.Errorf("restore snapshot %s: %w", path, err)
+	}
+	return path, n, nil
+}
+
+// Run saves a snapshot of store every interval until ctx is done.
+func (d SnapshotDir) Run(ctx context.Context, store Snapshotter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			path, n, err := d.Save(ctx, store)
+			if err != nil {
+				log.Printf("Error saving snapshot: %v", err)
+				continue
+			}
+			log.Printf("Saved snapshot of %d entries to %s", n, path)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/domain"
+	"ports-service/internal/ports"
+)
+
+func TestMemDB_SnapshotRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := database.NewMemDB[domain.Port]()
+	require.NoError(t, source.Set(ctx, "NLRTM", domain.Port{Key: "NLRTM", Name: "Rotterdam", Coordinates: &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}, Unlocs: []string{"NLRTM"}}))
+	require.NoError(t, source.Set(ctx, "DEHAM", domain.Port{Key: "DEHAM", Name: "Hamburg", Unlocs: []string{"DEHAM"}}))
+
+	for _, format := range []database.SnapshotFormat{database.SnapshotJSON, database.SnapshotNDJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := source.Snapshot(ctx, &buf, format)
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+
+			target := database.NewMemDB[domain.Port]()
+			n, err = target.Restore(ctx, &buf, format)
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+
+			for _, key := range []string{"NLRTM", "DEHAM"} {
+				want, err := source.Get(ctx, key)
+				require.NoError(t, err)
+				got, err := target.Get(ctx, key)
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	_, err := source.Snapshot(ctx, &buf, database.SnapshotNDJSON)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], `{"key":"DEHAM","value":{`), lines[0])
+}
+
+// TestMemDB_RestorePortsFile restores the bundled ports file, which is in
+// the keyed JSON format of a snapshot but has no key in its values.
+func TestMemDB_RestorePortsFile(t *testing.T) {
+	file, err := os.Open("../../../data/ports.json")
+	require.NoError(t, err)
+	defer file.Close()
+
+	ctx := context.Background()
+	db := database.NewMemDB[domain.Port]()
+	n, err := db.Restore(ctx, file, database.SnapshotJSON)
+	require.NoError(t, err)
+	assert.Equal(t, 1632, n)
+
+	port, err := db.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, "NLRTM", port.Key)
+	assert.Equal(t, "Rotterdam", port.Name)
+}
+
+func TestRestore_ReplacesContents(t *testing.T) {
+	ctx := context.Background()
+	source := database.NewMemDB[string]()
+	require.NoError(t, source.Set(ctx, "one", "one"))
+	require.NoError(t, source.Set(ctx, "two", "two"))
+	var buf bytes.Buffer
+	_, err := source.Snapshot(ctx, &buf, database.SnapshotNDJSON)
+	require.NoError(t, err)
+	snapshot := buf.String()
+
+	path := filepath.Join(t.TempDir(), "strings.log")
+	fileDB, err := database.OpenFileDB[string](path, database.FileDBOptions{})
+	require.NoError(t, err)
+	defer fileDB.Close()
+
+	for name, target := range map[string]interface {
+		ports.Store[string]
+		database.Snapshotter
+	}{"memory": database.NewMemDB[string](), "file": fileDB} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, target.Set(ctx, "one", "changed"))
+			require.NoError(t, target.Set(ctx, "three", "three"))
+
+			empty, err := database.IsEmpty[string](ctx, target)
+			require.NoError(t, err)
+			assert.False(t, empty)
+
+			n, err := target.Restore(ctx, strings.NewReader(snapshot), database.SnapshotNDJSON)
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+
+			page, err := target.List(ctx, ports.ListOptions[string]{})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"one", "two"}, page.Items)
+
+			// A snapshot that cannot be read changes nothing.
+			_, err = target.Restore(ctx, strings.NewReader(`{"key": "four", "value": "four"}`+"\n{"), database.SnapshotNDJSON)
+			assert.Error(t, err)
+			_, err = target.Get(ctx, "four")
+			assert.ErrorIs(t, err, ports.ErrNotFound)
+		})
+	}
+
+	// The restored contents of the file store survive a restart.
+	require.NoError(t, fileDB.Close())
+	reopened, err := database.OpenFileDB[string](path, database.FileDBOptions{})
+	require.NoError(t, err)
+	defer reopened.Close()
+	_, err = reopened.Get(ctx, "three")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+	assert.Equal(t, 2, reopened.Len())
+}
+
+func TestSnapshotDir(t *testing.T) {
+	ctx := context.Background()
+	dir := database.SnapshotDir{Dir: filepath.Join(t.TempDir(), "snapshots"), Format: database.SnapshotNDJSON, Keep: 2}
+
+	// Nothing to restore yet.
+	path, n, err := dir.RestoreLatest(ctx, database.NewMemDB[string]())
+	require.NoError(t, err)
+	assert.Empty(t, path)
+	assert.Zero(t, n)
+
+	db := database.NewMemDB[string]()
+	for _, value := range []string{"one", "two", "three"} {
+		require.NoError(t, db.Set(ctx, value, value))
+		_, _, err := dir.Save(ctx, db)
+		require.NoError(t, err)
+	}
+	entries, err := os.ReadDir(dir.Dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "older snapshots are pruned")
+
+	// The newest snapshot wins, even when new ones are written as JSON.
+	require.NoError(t, db.Delete(ctx, "one"))
+	dir.Format = database.SnapshotJSON
+	latest, _, err := dir.Save(ctx, db)
+	require.NoError(t, err)
+
+	restored := database.NewMemDB[string]()
+	path, n, err = dir.RestoreLatest(ctx, restored)
+	require.NoError(t, err)
+	assert.Equal(t, latest, path)
+	assert.Equal(t, 2, n)
+	_, err = restored.Get(ctx, "one")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+	value, err := restored.Get(ctx, "three")
+	require.NoError(t, err)
+	assert.Equal(t, "three", value)
+}
+
+func TestFileDB_RestorePersists(t *testing.T) {
+	ctx := context.Background()
+	source := database.NewMemDB[domain.Port]()
+	require.NoError(t, source.Set(ctx, "NLRTM", domain.Port{Key: "NLRTM", Name: "Rotterdam"}))
+	var buf bytes.Buffer
+	_, err := source.Snapshot(ctx, &buf, database.SnapshotJSON)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "ports.log")
+	db := openFileDB(t, path, database.FileDBOptions{})
+	_, err = db.Restore(ctx, &buf, database.SnapshotJSON)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db = openFileDB(t, path, database.FileDBOptions{})
+	port, err := db.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam", port.Name)
+}
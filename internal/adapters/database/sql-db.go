@@ -0,0 +1,463 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"ports-service/internal/domain"
+	"ports-service/internal/ports"
+)
+
+// sqlChildTables maps the list fields of a Port onto the child tables
+// holding them, see sqlMigrations.
+var sqlChildTables = []struct {
+	table, column string
+	field         func(*domain.Port) *[]string
+}{
+	{table: "port_alias", column: "alias", field: func(p *domain.Port) *[]string { return &p.Alias }},
+	{table: "port_regions", column: "region", field: func(p *domain.Port) *[]string { return &p.Regions }},
+	{table: "port_unlocs", column: "unloc", field: func(p *domain.Port) *[]string { return &p.Unlocs }},
+}
+
+const sqlPortColumns = `port_key, name, city, country, province, timezone, code, latitude, longitude, revision, updated_at, deleted_at`
+
+// SQLDB is a ports.Store of Ports in a relational database, accessed
+// through database/sql. A Port is a row of the ports table, its alias,
+// regions and unlocs are rows of child tables. Queries use ? placeholders
+// and INSERT ... ON CONFLICT upserts, as understood by SQLite.
+//
+// Like MemDB it maintains indexes on every write. They are filled from the
+// database when it is opened, so only one SQLDB may write to a database.
+// An SQLDB is safe for concurrent use; create one with OpenSQLDB.
+type SQLDB struct {
+	db      *sql.DB
+	mu      sync.Mutex // Serializes writes, so indexes see them in commit order.
+	indexes []Index[domain.Port]
+}
+
+// OpenSQLDB migrates the schema of db and loads the stored Ports into the
+// given indexes. The caller keeps ownership of db.
+func OpenSQLDB(ctx context.Context, db *sql.DB, indexes ...Index[domain.Port]) (*SQLDB, error) {
+	if _, err := MigrateSQL(ctx, db); err != nil {
+		return nil, err
+	}
+
+	s := &SQLDB{db: db, indexes: indexes}
+	if len(indexes) == 0 {
+		return s, nil
+	}
+
+	var pageToken string
+	for {
+		page, err := s.List(ctx, ports.ListOptions[domain.Port]{PageToken: pageToken, PageSize: 1000})
+		if err != nil {
+			return nil, fmt.Errorf("load indexes: %w", err)
+		}
+		for _, port := range page.Items {
+			for _, index := range indexes {
+				index.Put(port.Key, nil, port)
+			}
+		}
+		if page.NextPageToken == "" {
+			return s, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// Set inserts port under key or replaces the Port stored there.
+func (s *SQLDB) Set(ctx context.Context, key string, port domain.Port) error {
+	_, err := s.Upsert(ctx, key, port)
+	return err
+}
+
+// Upsert inserts port under key or replaces the Port stored there, unless
+// that one equals port.
+func (s *SQLDB) Upsert(ctx context.Context, key string, port domain.Port) (ports.UpsertOutcome, error) {
+	return s.Update(ctx, key, func(*domain.Port) (domain.Port, error) { return port, nil })
+}
+
+// Update stores the Port fn derives from the one stored under key, see
+// ports.Store. fn runs within the transaction writing the Port.
+func (s *SQLDB) Update(ctx context.Context, key string, fn func(old *domain.Port) (domain.Port, error)) (ports.UpsertOutcome, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var old *domain.Port
+	var port domain.Port
+	outcome := ports.Created
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		existing, err := s.getPort(ctx, tx, key)
+		switch {
+		case err == nil:
+			old = &existing
+			outcome = ports.Updated
+		case !errors.Is(err, ports.ErrNotFound):
+			return err
+		}
+		if port, err = fn(old); err != nil {
+			return err
+		}
+		if old != nil && old.Equal(port) {
+			outcome = ports.Unchanged
+			return nil
+		}
+		return s.writePort(ctx, tx, key, port)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if outcome == ports.Unchanged {
+		return outcome, nil
+	}
+
+	for _, index := range s.indexes {
+		index.Put(key, old, port)
+	}
+	return outcome, nil
+}
+
+// writePort upserts the row of port and replaces its child rows.
+func (s *SQLDB) writePort(ctx context.Context, tx *sql.Tx, key string, port domain.Port) error {
+	var latitude, longitude sql.NullFloat64
+	if port.Coordinates != nil {
+		latitude = sql.NullFloat64{Float64: port.Coordinates.Lat, Valid: true}
+		longitude = sql.NullFloat64{Float64: port.Coordinates.Lon, Valid: true}
+	}
+	var updatedAt int64
+	if port.UpdatedAt != nil {
+		updatedAt = toUnixNano(*port.UpdatedAt)
+	}
+	var deletedAt sql.NullInt64
+	if port.DeletedAt != nil {
+		deletedAt = sql.NullInt64{Int64: port.DeletedAt.UnixNano(), Valid: true}
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO ports (`+sqlPortColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (port_key) DO UPDATE SET
+			name = excluded.name, city = excluded.city, country = excluded.country,
+			province = excluded.province, timezone = excluded.timezone, code = excluded.code,
+			latitude = excluded.latitude, longitude = excluded.longitude,
+			revision = excluded.revision, updated_at = excluded.updated_at, deleted_at = excluded.deleted_at`,
+		key, port.Name, port.City, port.Country, port.Province, port.Timezone, port.Code, latitude, longitude,
+		port.Revision, updatedAt, deletedAt)
+	if err != nil {
+		return fmt.Errorf("upsert port %s: %w", key, err)
+	}
+
+	// Revision 0 is not stamped by a domain.StorePortRepository, so there
+	// is nothing to record.
+	if port.Revision > 0 && port.UpdatedAt != nil {
+		revision := domain.PortRevision{Revision: port.Revision, Time: *port.UpdatedAt}
+		if !port.Deleted() {
+			revision.Port = &port
+		}
+		if err := appendRevision(ctx, tx, key, revision); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range sqlChildTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+child.table+` WHERE port_key = ?`, key); err != nil {
+			return fmt.Errorf("upsert port %s: %w", key, err)
+		}
+		for position, value := range *child.field(&port) {
+			_, err := tx.ExecContext(ctx, `INSERT INTO `+child.table+` (port_key, position, `+child.column+`) VALUES (?, ?, ?)`,
+				key, position, value)
+			if err != nil {
+				return fmt.Errorf("upsert port %s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Get returns the Port stored under key.
+func (s *SQLDB) Get(ctx context.Context, key string) (domain.Port, error) {
+	var port domain.Port
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		var err error
+		port, err = s.getPort(ctx, tx, key)
+		return err
+	})
+	return port, err
+}
+
+func (s *SQLDB) getPort(ctx context.Context, tx *sql.Tx, key string) (domain.Port, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT `+sqlPortColumns+` FROM ports WHERE port_key = ?`, key)
+	if err != nil {
+		return domain.Port{}, fmt.Errorf("query port %s: %w", key, err)
+	}
+	found, err := scanPorts(rows)
+	if err != nil {
+		return domain.Port{}, fmt.Errorf("query port %s: %w", key, err)
+	}
+	if len(found) == 0 {
+		return domain.Port{}, &ports.NotFoundError{Key: key}
+	}
+	if err := s.loadChildren(ctx, tx, found, key, key); err != nil {
+		return domain.Port{}, fmt.Errorf("query port %s: %w", key, err)
+	}
+	return found[0], nil
+}
+
+// Delete removes the Port stored under key.
+func (s *SQLDB) Delete(ctx context.Context, key string) error {
+	_, err := s.DeleteIf(ctx, key, func(domain.Port) bool { return true })
+	return err
+}
+
+// DeleteIf removes the Port stored under key if cond returns true for it.
+func (s *SQLDB) DeleteIf(ctx context.Context, key string, cond func(domain.Port) bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var old domain.Port
+	deleted := false
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		var err error
+		if old, err = s.getPort(ctx, tx, key); err != nil {
+			return err
+		}
+		if !cond(old) {
+			return nil
+		}
+		// Foreign keys are off by default in SQLite, do not rely on the cascade.
+		for _, child := range sqlChildTables {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+child.table+` WHERE port_key = ?`, key); err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM ports WHERE port_key = ?`, key)
+		deleted = err == nil
+		return err
+	})
+	if errors.Is(err, ports.ErrNotFound) {
+		return false, err
+	}
+	if err != nil {
+		return false, fmt.Errorf("delete port %s: %w", key, err)
+	}
+	if !deleted {
+		return false, nil
+	}
+
+	for _, index := range s.indexes {
+		index.Remove(key, old)
+	}
+	return true, nil
+}
+
+// List returns Ports in key order, see MemDB.List. Rows are read in batches
+// of the page size until the page is full, as the filter runs in Go.
+func (s *SQLDB) List(ctx context.Context, opts ports.ListOptions[domain.Port]) (ports.Page[domain.Port], error) {
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	var page ports.Page[domain.Port]
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		after := opts.PageToken
+		for {
+			rows, err := tx.QueryContext(ctx, `SELECT `+sqlPortColumns+` FROM ports
+				WHERE port_key > ? ORDER BY port_key LIMIT ?`, after, pageSize+1)
+			if err != nil {
+				return err
+			}
+			batch, err := scanPorts(rows)
+			if err != nil {
+				return err
+			}
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := s.loadChildren(ctx, tx, batch, batch[0].Key, batch[len(batch)-1].Key); err != nil {
+				return err
+			}
+
+			for _, port := range batch {
+				if opts.Filter != nil && !opts.Filter(port) {
+					continue
+				}
+				if len(page.Items) == pageSize {
+					// At least one more match exists.
+					page.NextPageToken = page.Items[len(page.Items)-1].Key
+					return nil
+				}
+				page.Items = append(page.Items, port)
+			}
+			if len(batch) <= pageSize {
+				return nil
+			}
+			after = batch[len(batch)-1].Key
+		}
+	})
+	if err != nil {
+		return ports.Page[domain.Port]{}, fmt.Errorf("list ports: %w", err)
+	}
+	return page, nil
+}
+
+// Len returns the number of stored Ports.
+func (s *SQLDB) Len(ctx context.Context) (int, error) {
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM ports`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count ports: %w", err)
+	}
+	return n, nil
+}
+
+func scanPorts(rows *sql.Rows) ([]domain.Port, error) {
+	defer rows.Close()
+
+	var found []domain.Port
+	for rows.Next() {
+		var port domain.Port
+		var latitude, longitude sql.NullFloat64
+		var updatedAt int64
+		var deletedAt sql.NullInt64
+		err := rows.Scan(&port.Key, &port.Name, &port.City, &port.Country, &port.Province,
+			&port.Timezone, &port.Code, &latitude, &longitude, &port.Revision, &updatedAt, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		if latitude.Valid && longitude.Valid {
+			port.Coordinates = &domain.GeoPoint{Lat: latitude.Float64, Lon: longitude.Float64}
+		}
+		if updatedAt != 0 {
+			at := fromUnixNano(updatedAt)
+			port.UpdatedAt = &at
+		}
+		if deletedAt.Valid {
+			at := fromUnixNano(deletedAt.Int64)
+			port.DeletedAt = &at
+		}
+		found = append(found, port)
+	}
+	return found, rows.Err()
+}
+
+// loadChildren fills the list fields of found, which holds the Ports with
+// keys from first to last in key order.
+func (s *SQLDB) loadChildren(ctx context.Context, tx *sql.Tx, found []domain.Port, first, last string) error {
+	byKey := make(map[string]*domain.Port, len(found))
+	for i := range found {
+		byKey[found[i].Key] = &found[i]
+	}
+
+	for _, child := range sqlChildTables {
+		rows, err := tx.QueryContext(ctx, `SELECT port_key, `+child.column+` FROM `+child.table+`
+			WHERE port_key >= ? AND port_key <= ? ORDER BY port_key, position`, first, last)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var key, value string
+			if err := rows.Scan(&key, &value); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			if port, ok := byKey[key]; ok {
+				field := child.field(port)
+				*field = append(*field, value)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Append adds revision to the history of the Port under key, so an SQLDB
+// also implements domain.PortHistoryStore. Revisions from the same one on
+// are replaced. Every stamped Port an SQLDB writes is appended in the same
+// transaction already, see RecordsWrites.
+func (s *SQLDB) Append(ctx context.Context, key string, revision domain.PortRevision) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		return appendRevision(ctx, tx, key, revision)
+	})
+}
+
+// RecordsWrites makes an SQLDB a domain.WriteRecorder.
+func (s *SQLDB) RecordsWrites() {}
+
+func appendRevision(ctx context.Context, tx *sql.Tx, key string, revision domain.PortRevision) error {
+	var port sql.NullString
+	if revision.Port != nil {
+		data, err := json.Marshal(revision.Port)
+		if err != nil {
+			return fmt.Errorf("encode revision %d of port %s: %w", revision.Revision, key, err)
+		}
+		port = sql.NullString{String: string(data), Valid: true}
+	}
+
+	_, err := tx.ExecContext(ctx, `DELETE FROM port_history WHERE port_key = ? AND revision >= ?`, key, revision.Revision)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `INSERT INTO port_history (port_key, revision, time, port) VALUES (?, ?, ?, ?)`,
+			key, revision.Revision, toUnixNano(revision.Time), port)
+	}
+	if err != nil {
+		return fmt.Errorf("append revision %d of port %s: %w", revision.Revision, key, err)
+	}
+	return nil
+}
+
+// History returns the revisions of the Port under key, oldest first.
+func (s *SQLDB) History(ctx context.Context, key string) ([]domain.PortRevision, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT revision, time, port FROM port_history
+		WHERE port_key = ? ORDER BY revision`, key)
+	if err != nil {
+		return nil, fmt.Errorf("query history of port %s: %w", key, err)
+	}
+	defer rows.Close()
+
+	var revisions []domain.PortRevision
+	for rows.Next() {
+		var revision domain.PortRevision
+		var unixNano int64
+		var port sql.NullString
+		if err := rows.Scan(&revision.Revision, &unixNano, &port); err != nil {
+			return nil, fmt.Errorf("query history of port %s: %w", key, err)
+		}
+		revision.Time = fromUnixNano(unixNano)
+		if port.Valid {
+			revision.Port = new(domain.Port)
+			if err := json.Unmarshal([]byte(port.String), revision.Port); err != nil {
+				return nil, fmt.Errorf("decode revision %d of port %s: %w", revision.Revision, key, err)
+			}
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query history of port %s: %w", key, err)
+	}
+	return revisions, nil
+}
+
+// toUnixNano stores t as nanoseconds since the Unix epoch, 0 for the zero
+// time.
+func toUnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(unixNano int64) time.Time {
+	if unixNano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, unixNano).UTC()
+}
@@ -0,0 +1,233 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver, registers "sqlite".
+
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/adapters/streamfromfile"
+	"ports-service/internal/domain"
+	"ports-service/internal/ports"
+)
+
+func openSQL(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestSQLDB_SetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	store, err := database.OpenSQLDB(ctx, openSQL(t, filepath.Join(t.TempDir(), "ports.db")))
+	require.NoError(t, err)
+
+	rotterdam := domain.Port{
+		Key:         "NLRTM",
+		Name:        "Rotterdam",
+		City:        "Rotterdam",
+		Country:     "Netherlands",
+		Alias:       []string{"Europoort", "Maasvlakte"},
+		Regions:     []string{"Zuid-Holland"},
+		Coordinates: &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917},
+		Province:    "Zuid-Holland",
+		Timezone:    "Europe/Amsterdam",
+		Unlocs:      []string{"NLRTM", "NLEUR"},
+		Code:        "42157",
+	}
+	require.NoError(t, store.Set(ctx, rotterdam.Key, rotterdam))
+
+	got, err := store.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, rotterdam, got)
+
+	// Storing the same port again writes nothing.
+	outcome, err := store.Upsert(ctx, rotterdam.Key, rotterdam)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Unchanged, outcome)
+
+	// Upserting replaces the child rows, it does not add to them.
+	updated := rotterdam
+	updated.Alias = []string{"Maasvlakte"}
+	updated.Regions = nil
+	updated.Coordinates = nil
+	outcome, err = store.Upsert(ctx, updated.Key, updated)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Updated, outcome)
+	got, err = store.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, updated, got)
+	// Empty lists equal the nil ones read back.
+	updated.Regions = []string{}
+	outcome, err = store.Upsert(ctx, updated.Key, updated)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Unchanged, outcome)
+	n, err := store.Len(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	require.NoError(t, store.Delete(ctx, "NLRTM"))
+	_, err = store.Get(ctx, "NLRTM")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+	assert.ErrorIs(t, store.Delete(ctx, "NLRTM"), ports.ErrNotFound)
+}
+
+func TestSQLDB_List(t *testing.T) {
+	ctx := context.Background()
+	store, err := database.OpenSQLDB(ctx, openSQL(t, filepath.Join(t.TempDir(), "ports.db")))
+	require.NoError(t, err)
+
+	for i := 0; i < 25; i++ {
+		key := "P" + strconv.Itoa(100+i)
+		port := domain.Port{Key: key, Unlocs: []string{key}}
+		if i%3 == 0 {
+			port.Country = "Netherlands"
+		}
+		require.NoError(t, store.Set(ctx, key, port))
+	}
+
+	var keys []string
+	var pageToken string
+	for {
+		page, err := store.List(ctx, ports.ListOptions[domain.Port]{
+			PageToken: pageToken,
+			PageSize:  2,
+			Filter:    func(p domain.Port) bool { return p.Country == "Netherlands" },
+		})
+		require.NoError(t, err)
+		for _, port := range page.Items {
+			keys = append(keys, port.Key)
+			assert.Equal(t, []string{port.Key}, port.Unlocs)
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	assert.Equal(t, []string{"P100", "P103", "P106", "P109", "P112", "P115", "P118", "P121", "P124"}, keys)
+}
+
+// TestSQLDB_Reopen checks migrations run once and indexes are loaded from
+// the rows already stored.
+func TestSQLDB_Reopen(t *testing.T) {
+	ctx := context.Background()
+	db := openSQL(t, filepath.Join(t.TempDir(), "ports.db"))
+
+	store, err := database.OpenSQLDB(ctx, db)
+	require.NoError(t, err)
+	require.NoError(t, store.Set(ctx, "AEAUH", domain.Port{Key: "AEAUH", Name: "Abu Dhabi", Country: "United Arab Emirates"}))
+
+	country := database.NewFieldIndex(domain.FieldCountry.Values)
+	store, err = database.OpenSQLDB(ctx, db, country)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"AEAUH"}, country.Lookup("united arab emirates"))
+
+	require.NoError(t, store.Set(ctx, "AEAUH", domain.Port{Key: "AEAUH", Name: "Abu Dhabi", Country: "UAE"}))
+	assert.Empty(t, country.Lookup("united arab emirates"))
+	assert.Equal(t, []string{"AEAUH"}, country.Lookup("UAE"))
+
+	version, err := database.MigrateSQL(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, 3, version)
+}
+
+func TestSQLDB_History(t *testing.T) {
+	ctx := context.Background()
+	store, err := database.OpenSQLDB(ctx, openSQL(t, filepath.Join(t.TempDir(), "ports.db")))
+	require.NoError(t, err)
+	repo := domain.StorePortRepository{Data: store, History: store}
+
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}}
+	require.NoError(t, repo.Store(ctx, rotterdam))
+	rotterdam.Name = "Rotterdam Europoort"
+	require.NoError(t, repo.Store(ctx, rotterdam))
+	require.NoError(t, repo.Delete(ctx, "NLRTM"))
+	require.NoError(t, repo.Store(ctx, rotterdam))
+
+	got, err := repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), got.Revision)
+
+	revisions, err := repo.GetPortHistory(ctx, "NLRTM")
+	require.NoError(t, err)
+	require.Len(t, revisions, 4)
+	assert.Equal(t, "Rotterdam", revisions[0].Port.Name)
+	assert.Equal(t, uint64(2), revisions[1].Port.Revision)
+	assert.Nil(t, revisions[2].Port)
+	assert.Equal(t, got, *revisions[3].Port)
+
+	asOf, err := repo.GetPortAsOf(ctx, "NLRTM", revisions[1].Time)
+	require.NoError(t, err)
+	assert.Equal(t, *revisions[1].Port, asOf)
+	// A stale expected revision rolls the transaction back.
+	_, err = repo.UpsertIfRevision(ctx, domain.Port{Key: "NLRTM", Name: "Stale", Unlocs: []string{"NLRTM"}}, 2)
+	assert.ErrorIs(t, err, domain.ErrRevisionConflict)
+	got, err = repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam Europoort", got.Name)
+	revisions, err = repo.GetPortHistory(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 4)
+
+	// The store records the revisions it writes without a separate Append.
+	hamburg := domain.Port{Key: "DEHAM", Name: "Hamburg", Unlocs: []string{"DEHAM"}, Revision: 7, UpdatedAt: &revisions[0].Time}
+	require.NoError(t, store.Set(ctx, "DEHAM", hamburg))
+	revisions, err = store.History(ctx, "DEHAM")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, uint64(7), revisions[0].Revision)
+}
+
+// TestSQLDB_Repository ingests a sample of the bundled ports file through
+// the domain repository and reads every Port back. The sample keeps the test
+// fast under the race detector, which slows the SQLite driver down a lot.
+func TestSQLDB_Repository(t *testing.T) {
+	ctx := context.Background()
+	store, err := database.OpenSQLDB(ctx, openSQL(t, filepath.Join(t.TempDir(), "ports.db")))
+	require.NoError(t, err)
+	repo := domain.StorePortRepository{Data: store}
+
+	var all []domain.Port
+	ch, err := streamfromfile.NewFileStreamer[domain.Port]("../../../data/ports.json").StreamObjects(ctx, 100)
+	require.NoError(t, err)
+	for port := range ch {
+		if len(all) == 20 && port.Key != "NLRTM" {
+			continue
+		}
+		if err := repo.Store(ctx, port); err != nil {
+			assert.ErrorIs(t, err, domain.ErrInvalidPort)
+		}
+		all = append(all, port)
+	}
+
+	found, err := repo.FindByUnloc(ctx, "nlrtm")
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "Rotterdam", found[0].Name)
+
+	for _, want := range all {
+		got, err := store.Get(ctx, want.Key)
+		if err != nil {
+			assert.ErrorIs(t, err, ports.ErrNotFound)
+			continue
+		}
+		// Empty lists come back as nil.
+		for _, field := range []*[]string{&want.Alias, &want.Regions, &want.Unlocs} {
+			if len(*field) == 0 {
+				*field = nil
+			}
+		}
+		assert.NotZero(t, got.Revision, want.Key)
+		assert.NotNil(t, got.UpdatedAt, want.Key)
+		got.Revision, got.UpdatedAt = 0, nil
+		assert.Equal(t, want, got, want.Key)
+	}
+}
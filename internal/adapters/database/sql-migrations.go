@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// sqlMigrations evolve the schema of an SQLDB. Each entry is applied once, in
+// order, and recorded in schema_migrations; append new migrations instead of
+// editing applied ones.
+var sqlMigrations = []string{
+	// 1: Ports with their list fields in child tables, ordered by position.
+	`CREATE TABLE ports (
+		port_key  TEXT PRIMARY KEY,
+		name      TEXT NOT NULL,
+		city      TEXT NOT NULL,
+		country   TEXT NOT NULL,
+		province  TEXT NOT NULL,
+		timezone  TEXT NOT NULL,
+		code      TEXT NOT NULL,
+		latitude  REAL,
+		longitude REAL
+	);
+	CREATE TABLE port_alias (
+		port_key TEXT NOT NULL REFERENCES ports (port_key) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		alias    TEXT NOT NULL,
+		PRIMARY KEY (port_key, position)
+	);
+	CREATE TABLE port_regions (
+		port_key TEXT NOT NULL REFERENCES ports (port_key) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		region   TEXT NOT NULL,
+		PRIMARY KEY (port_key, position)
+	);
+	CREATE TABLE port_unlocs (
+		port_key TEXT NOT NULL REFERENCES ports (port_key) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		unloc    TEXT NOT NULL,
+		PRIMARY KEY (port_key, position)
+	);
+	CREATE INDEX ports_country ON ports (country);
+	CREATE INDEX ports_code ON ports (code);
+	CREATE INDEX port_alias_alias ON port_alias (alias);
+	CREATE INDEX port_unlocs_unloc ON port_unlocs (unloc);`,
+	// 2: Revisions of the Ports, with the history of every revision as JSON.
+	`ALTER TABLE ports ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE ports ADD COLUMN updated_at INTEGER NOT NULL DEFAULT 0;
+	CREATE TABLE port_history (
+		port_key TEXT NOT NULL,
+		revision INTEGER NOT NULL,
+		time     INTEGER NOT NULL,
+		port     TEXT,
+		PRIMARY KEY (port_key, revision)
+	);`,
+	// 3: Tombstones of deleted Ports, NULL for live ones.
+	`ALTER TABLE ports ADD COLUMN deleted_at INTEGER;`,
+}
+
+// MigrateSQL brings the schema of db up to date, applying every migration
+// not yet recorded in schema_migrations in its own transaction. It returns
+// the resulting schema version.
+func MigrateSQL(ctx context.Context, db *sql.DB) (int, error) {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return 0, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var version int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	if version > len(sqlMigrations) {
+		return version, fmt.Errorf("schema version %d is newer than the %d migrations known to this build", version, len(sqlMigrations))
+	}
+
+	for ; version < len(sqlMigrations); version++ {
+		err := inTx(ctx, db, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, sqlMigrations[version]); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, version+1)
+			return err
+		})
+		if err != nil {
+			return version, fmt.Errorf("apply migration %d: %w", version+1, err)
+		}
+	}
+	return version, nil
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds.
+func inTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,397 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ports-service/internal/ports"
+)
+
+// DefaultWALSegmentSize is used when WALOptions.SegmentSize is not set.
+const DefaultWALSegmentSize = 16 << 20
+
+// WALOptions tunes the segments of a WALStore.
+type WALOptions struct {
+	// SegmentSize is the size in bytes after which the WAL moves on to a
+	// new segment file. Defaults to DefaultWALSegmentSize.
+	SegmentSize int64
+	// Retention is how long a segment is kept once a checkpoint covers all
+	// of its records. Segments not covered by a checkpoint are never
+	// removed, as replaying them is the only way to recover their writes.
+	Retention time.Duration
+}
+
+// walSegment is a segment file of a WALStore holding the records from
+// first to last.
+type walSegment struct {
+	path        string
+	first, last uint64
+}
+
+const (
+	walSegmentPrefix = "wal-"
+	walSegmentExt    = ".log"
+)
+
+// WALStore is a write-ahead log in front of a ports.Store. Every write is
+// appended to the log and synced to disk before it is applied to the store,
+// so once Set returns the write survives a crash; opening a WALStore replays
+// the log into the store. Reads go straight to the store.
+//
+// The log is split into segment files of about WALOptions.SegmentSize
+// bytes, named after the sequence number of their first record and using
+// the record format of FileDB. Saving a snapshot of the store through a
+// SnapshotDir checkpoints the log, after which segments older than
+// WALOptions.Retention are removed.
+// A WALStore is safe for concurrent use; create one with OpenWALStore.
+type WALStore[T any] struct {
+	store ports.Store[T]
+	dir   string
+	opts  WALOptions
+
+	mu         sync.Mutex   // Serializes writes, so the log and the store apply them in the same order.
+	file       *os.File     // Active segment, nil until the first write after opening and once closed.
+	active     walSegment   // Segment of file.
+	size       int64        // Bytes written to file.
+	seq        uint64       // Sequence number of the last record.
+	sealed     []walSegment // Segments before the active one, oldest first.
+	checkpoint uint64       // Records up to here are captured elsewhere.
+	closed     bool
+}
+
+// OpenWALStore opens the WAL in dir, creating the directory if needed, and
+// replays its records into store. Restore snapshots into store before
+// opening the WAL, so the replay is applied on top of them. Close the
+// WALStore to release the active segment.
+func OpenWALStore[T any](dir string, opts WALOptions, store ports.Store[T]) (*WALStore[T], error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultWALSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create WAL dir: %w", err)
+	}
+
+	w := &WALStore[T]{store: store, dir: dir, opts: opts}
+	if err := w.replay(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// replay applies the records of every segment, oldest first, to the store.
+func (w *WALStore[T]) replay() error {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return fmt.Errorf("list WAL segments: %w", err)
+	}
+	var firsts []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, walSegmentPrefix) || !strings.HasSuffix(name, walSegmentExt) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, walSegmentPrefix), walSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		firsts = append(firsts, first)
+	}
+	sort.Slice(firsts, func(i, j int) bool { return firsts[i] < firsts[j] })
+
+	ctx := context.Background()
+	replayed := 0
+	for _, first := range firsts {
+		segment := walSegment{path: w.segmentPath(first), first: first, last: first - 1}
+		n, err := w.replaySegment(ctx, &segment)
+		if err != nil {
+			return err
+		}
+		replayed += n
+		w.seq = max(w.seq, segment.last)
+		w.sealed = append(w.sealed, segment)
+	}
+	if replayed > 0 {
+		log.Printf("Replayed %d records from the WAL in %s", replayed, w.dir)
+	}
+	return nil
+}
+
+func (w *WALStore[T]) replaySegment(ctx context.Context, segment *walSegment) (int, error) {
+	file, err := os.Open(segment.path)
+	if err != nil {
+		return 0, fmt.Errorf("open WAL segment: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	replayed := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("Ignoring %d bytes of a torn record at the end of %s", len(line), segment.path)
+			}
+			return replayed, nil
+		}
+		if err != nil {
+			return replayed, fmt.Errorf("read WAL segment: %w", err)
+		}
+
+		record, err := decodeRecord[T](line)
+		if err != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				log.Printf("Ignoring a torn record at the end of %s", segment.path)
+				return replayed, nil
+			}
+			return replayed, fmt.Errorf("WAL segment %s corrupt at offset %d: %w", segment.path, offset, err)
+		}
+
+		switch record.Op {
+		case opSet:
+			err = w.store.Set(ctx, record.Key, *record.Value)
+		case opDelete:
+			err = w.store.Delete(ctx, record.Key)
+			if errors.Is(err, ports.ErrNotFound) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return replayed, fmt.Errorf("replay WAL record %d: %w", record.Seq, err)
+		}
+		offset += int64(len(line))
+		segment.last = record.Seq
+		replayed++
+	}
+}
+
+func (w *WALStore[T]) segmentPath(first uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%s%020d%s", walSegmentPrefix, first, walSegmentExt))
+}
+
+// append logs record and syncs it to disk. Writes after opening start a new
+// segment rather than appending to one a crash may have left torn.
+func (w *WALStore[T]) append(record fileRecord[T]) error {
+	if w.closed {
+		return ErrClosed
+	}
+	record.Seq = w.seq + 1
+	line, err := encodeRecord(record)
+	if err != nil {
+		return err
+	}
+
+	if w.file == nil || w.size >= w.opts.SegmentSize {
+		if err := w.rotate(record.Seq); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.file.Write(line); err != nil {
+		if truncErr := w.file.Truncate(w.size); truncErr == nil {
+			_, _ = w.file.Seek(w.size, io.SeekStart)
+		}
+		return fmt.Errorf("append to WAL: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("sync WAL: %w", err)
+	}
+	w.size += int64(len(line))
+	w.seq = record.Seq
+	w.active.last = record.Seq
+	return nil
+}
+
+// rotate seals the active segment and starts a new one with record first.
+func (w *WALStore[T]) rotate(first uint64) error {
+	path := w.segmentPath(first)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("create WAL segment: %w", err)
+	}
+	if err := syncDir(w.dir); err != nil {
+		log.Printf("Error syncing WAL dir %s: %v", w.dir, err)
+	}
+
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			log.Printf("Error closing WAL segment %s: %v", w.active.path, err)
+		}
+		w.sealed = append(w.sealed, w.active)
+	}
+	w.file = file
+	w.active = walSegment{path: path, first: first, last: first - 1}
+	w.size = 0
+
+	w.prune()
+	return nil
+}
+
+// prune removes the sealed segments covered by the checkpoint that are
+// older than the retention.
+func (w *WALStore[T]) prune() {
+	for len(w.sealed) > 0 {
+		segment := w.sealed[0]
+		if segment.last > w.checkpoint {
+			return
+		}
+		info, err := os.Stat(segment.path)
+		if err == nil && time.Since(info.ModTime()) < w.opts.Retention {
+			return
+		}
+		if err := os.Remove(segment.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing WAL segment %s: %v", segment.path, err)
+			return
+		}
+		w.sealed = w.sealed[1:]
+	}
+}
+
+// Set logs value under key, then stores it.
+func (w *WALStore[T]) Set(ctx context.Context, key string, value T) error {
+	_, err := w.Upsert(ctx, key, value)
+	return err
+}
+
+// Upsert logs value under key, then stores it. Nothing is logged if an
+// equal value is stored already.
+func (w *WALStore[T]) Upsert(ctx context.Context, key string, value T) (ports.UpsertOutcome, error) {
+	return w.Update(ctx, key, func(*T) (T, error) { return value, nil })
+}
+
+// Update logs the value fn derives from the one stored for key, then stores
+// it, see ports.Store. fn is called with the write lock held, so it must
+// not use w.
+func (w *WALStore[T]) Update(ctx context.Context, key string, fn func(old *T) (T, error)) (ports.UpsertOutcome, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	value, unchanged, err := derive(ctx, w.store, key, fn)
+	if err != nil {
+		return 0, err
+	}
+	if unchanged {
+		return ports.Unchanged, nil
+	}
+	if err := w.append(fileRecord[T]{Op: opSet, Key: key, Value: &value}); err != nil {
+		return 0, err
+	}
+	return w.store.Upsert(ctx, key, value)
+}
+
+// Delete logs the removal of key, then removes it from the store.
+func (w *WALStore[T]) Delete(ctx context.Context, key string) error {
+	_, err := w.DeleteIf(ctx, key, func(T) bool { return true })
+	return err
+}
+
+// DeleteIf logs the removal of key if cond returns true for its value, then
+// removes it from the store. cond is called with the write lock held, so it
+// must not use w.
+func (w *WALStore[T]) DeleteIf(ctx context.Context, key string, cond func(T) bool) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	old, err := w.store.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if !cond(old) {
+		return false, nil
+	}
+	if err := w.append(fileRecord[T]{Op: opDelete, Key: key}); err != nil {
+		return false, err
+	}
+	return true, w.store.Delete(ctx, key)
+}
+
+// Get returns the value stored for key.
+func (w *WALStore[T]) Get(ctx context.Context, key string) (T, error) {
+	return w.store.Get(ctx, key)
+}
+
+// List returns values of the store, see ports.Store.
+func (w *WALStore[T]) List(ctx context.Context, opts ports.ListOptions[T]) (ports.Page[T], error) {
+	return w.store.List(ctx, opts)
+}
+
+// Snapshot writes a snapshot of the store to w, if the store supports them.
+func (w *WALStore[T]) Snapshot(ctx context.Context, out io.Writer, format SnapshotFormat) (int, error) {
+	snapshotter, ok := w.store.(Snapshotter)
+	if !ok {
+		return 0, errors.New("store does not support snapshots")
+	}
+	return snapshotter.Snapshot(ctx, out, format)
+}
+
+// Restore replaces the contents of the store with the snapshot read from
+// r, see MemDB.Restore, logging every change like Set and Delete do. The
+// store has to be a MemDB or FileDB.
+func (w *WALStore[T]) Restore(ctx context.Context, r io.Reader, format SnapshotFormat) (int, error) {
+	var keys []string
+	switch store := w.store.(type) {
+	case *MemDB[T]:
+		keys = store.keys()
+	case *FileDB[T]:
+		keys = store.mem.keys()
+	default:
+		return 0, errors.New("store does not support snapshots")
+	}
+	return restoreAll[T](ctx, w, keys, r, format)
+}
+
+// LastSeq returns the sequence number of the last logged record.
+func (w *WALStore[T]) LastSeq() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.seq
+}
+
+// Checkpoint records that the writes up to seq are captured elsewhere, in a
+// snapshot, so the segments holding only them may be removed once they are
+// older than the retention.
+func (w *WALStore[T]) Checkpoint(seq uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.checkpoint = max(w.checkpoint, seq)
+	w.prune()
+}
+
+// Close releases the active segment. Reads keep working, writes fail with
+// ErrClosed.
+func (w *WALStore[T]) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,138 @@
+package database_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/ports"
+)
+
+func openWAL(t *testing.T, dir string, opts database.WALOptions, store ports.Store[string]) *database.WALStore[string] {
+	t.Helper()
+	wal, err := database.OpenWALStore[string](dir, opts, store)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = wal.Close() })
+	return wal
+}
+
+func walSegments(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestWALStore_ReplaysOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	wal := openWAL(t, dir, database.WALOptions{SegmentSize: 200}, database.NewMemDB[string]())
+	for i := 0; i < 20; i++ {
+		require.NoError(t, wal.Set(ctx, fmt.Sprintf("K%02d", i%5), fmt.Sprintf("v%d", i)))
+	}
+	require.NoError(t, wal.Delete(ctx, "K03"))
+	assert.ErrorIs(t, wal.Delete(ctx, "K03"), ports.ErrNotFound)
+	assert.Equal(t, uint64(21), wal.LastSeq())
+	outcome, err := wal.Upsert(ctx, "K04", "v19")
+	require.NoError(t, err)
+	assert.Equal(t, ports.Unchanged, outcome)
+	assert.Equal(t, uint64(21), wal.LastSeq(), "an unchanged value must not be logged")
+	require.NoError(t, wal.Close())
+	assert.ErrorIs(t, wal.Set(ctx, "K00", "v"), database.ErrClosed)
+	assert.Greater(t, len(walSegments(t, dir)), 1, "segments rotate once they reach the segment size")
+
+	// A crash loses the in-memory store, the WAL brings it back.
+	db := database.NewMemDB[string]()
+	wal = openWAL(t, dir, database.WALOptions{SegmentSize: 200}, db)
+	assert.Equal(t, uint64(21), wal.LastSeq())
+	assert.Equal(t, 4, db.Len())
+	for key, want := range map[string]string{"K00": "v15", "K01": "v16", "K02": "v17", "K04": "v19"} {
+		got, err := db.Get(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err = db.Get(ctx, "K03")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+}
+
+func TestWALStore_IgnoresTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	wal := openWAL(t, dir, database.WALOptions{}, database.NewMemDB[string]())
+	require.NoError(t, wal.Set(ctx, "NLRTM", "Rotterdam"))
+	require.NoError(t, wal.Set(ctx, "DEHAM", "Hamburg"))
+	require.NoError(t, wal.Close())
+
+	segments := walSegments(t, dir)
+	require.Len(t, segments, 1)
+	data, err := os.ReadFile(segments[0])
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(segments[0], data[:len(data)-5], 0o644))
+
+	db := database.NewMemDB[string]()
+	wal = openWAL(t, dir, database.WALOptions{}, db)
+	assert.Equal(t, 1, db.Len())
+
+	// New writes go to a new segment and are replayed after the torn one.
+	require.NoError(t, wal.Set(ctx, "DEHAM", "Hamburg"))
+	require.NoError(t, wal.Close())
+	db = database.NewMemDB[string]()
+	openWAL(t, dir, database.WALOptions{}, db)
+	assert.Equal(t, 2, db.Len())
+}
+
+// TestWALStore_CheckpointWithSnapshot removes the segments a snapshot
+// covers and recovers from the snapshot plus the remaining segments.
+func TestWALStore_CheckpointWithSnapshot(t *testing.T) {
+	walDir := t.TempDir()
+	snapshots := database.SnapshotDir{Dir: t.TempDir(), Format: database.SnapshotNDJSON, Keep: 1}
+	ctx := context.Background()
+
+	wal := openWAL(t, walDir, database.WALOptions{SegmentSize: 100}, database.NewMemDB[string]())
+	for i := 0; i < 10; i++ {
+		require.NoError(t, wal.Set(ctx, fmt.Sprintf("K%d", i), "before"))
+	}
+	before := len(walSegments(t, walDir))
+	_, _, err := snapshots.Save(ctx, wal)
+	require.NoError(t, err)
+	assert.Less(t, len(walSegments(t, walDir)), before, "checkpointed segments are removed")
+
+	require.NoError(t, wal.Set(ctx, "K0", "after"))
+	require.NoError(t, wal.Delete(ctx, "K1"))
+	require.NoError(t, wal.Close())
+
+	db := database.NewMemDB[string]()
+	_, n, err := snapshots.RestoreLatest(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, 10, n)
+	openWAL(t, walDir, database.WALOptions{SegmentSize: 100}, db)
+
+	assert.Equal(t, 9, db.Len())
+	value, err := db.Get(ctx, "K0")
+	require.NoError(t, err)
+	assert.Equal(t, "after", value)
+	_, err = db.Get(ctx, "K1")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+}
+
+func TestWALStore_RetentionKeepsRecentSegments(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	wal := openWAL(t, dir, database.WALOptions{SegmentSize: 100, Retention: 24 * time.Hour}, database.NewMemDB[string]())
+	for i := 0; i < 10; i++ {
+		require.NoError(t, wal.Set(ctx, fmt.Sprintf("K%d", i), "value"))
+	}
+	before := len(walSegments(t, dir))
+	wal.Checkpoint(wal.LastSeq())
+	assert.Len(t, walSegments(t, dir), before)
+}
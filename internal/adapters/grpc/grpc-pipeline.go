@@ -0,0 +1,354 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ports-service/internal/domain"
+	pb "ports-service/internal/gen/grpc"
+	"ports-service/internal/ports"
+)
+
+// Config tunes the ingest pipeline every streaming call runs.
+type Config struct {
+	BufferSize int // Ports buffered between the receive loop and the workers of one stream.
+	Workers    int // Goroutines storing the Ports of one stream concurrently.
+	// EnqueueTimeout is how long a received Port may wait for room in a
+	// full pipeline before the call fails with codes.ResourceExhausted.
+	// Zero waits for as long as the stream is alive.
+	EnqueueTimeout time.Duration
+	// MaxErrorDetails caps the rejections listed in the IngestSummary a
+	// StreamPorts call ends with. Defaults to DefaultMaxErrorDetails.
+	MaxErrorDetails int
+}
+
+// DefaultMaxErrorDetails is used when Config.MaxErrorDetails is not set.
+const DefaultMaxErrorDetails = 10
+
+func (c Config) withDefaults() Config {
+	if c.Workers <= 0 {
+		c.Workers = 1
+	}
+	if c.BufferSize < 0 {
+		c.BufferSize = 0
+	}
+	if c.EnqueueTimeout < 0 {
+		c.EnqueueTimeout = 0
+	}
+	if c.MaxErrorDetails <= 0 {
+		c.MaxErrorDetails = DefaultMaxErrorDetails
+	}
+	return c
+}
+
+// ingestItem is a received Port on its way to the repository.
+type ingestItem struct {
+	uuid   string
+	port   domain.Port
+	fields []domain.PortField // Fields to merge into the stored Port, nil to replace it.
+	// expectedRevision, when set, is the revision the stored Port must have.
+	expectedRevision *uint64
+}
+
+// ingestResult is the outcome of storing an ingestItem.
+type ingestResult struct {
+	ingestItem
+	outcome ports.UpsertOutcome // Set when err is nil.
+	err     error
+}
+
+// ingestPipeline stores the Ports received on a single stream. Items are
+// spread over the workers by key, so updates to the same Port are stored in
+// the order they arrived.
+type ingestPipeline struct {
+	ctx            context.Context
+	enqueueTimeout time.Duration
+	queues         []chan ingestItem
+	results        chan ingestResult
+	abandoned      chan struct{} // Closed once nobody reads results anymore.
+}
+
+// newPipeline starts the workers of a pipeline for one stream. Every
+// submitted item yields exactly one ingestResult; the results channel is
+// closed once close has been called and all items have been stored.
+func (p *PortServiceServer) newPipeline(ctx context.Context) *ingestPipeline {
+	queueSize := (p.config.BufferSize + p.config.Workers - 1) / p.config.Workers
+
+	pipeline := &ingestPipeline{
+		ctx:            ctx,
+		enqueueTimeout: p.config.EnqueueTimeout,
+		queues:         make([]chan ingestItem, p.config.Workers),
+		results:        make(chan ingestResult, p.config.BufferSize),
+		abandoned:      make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	for i := range pipeline.queues {
+		queue := make(chan ingestItem, queueSize)
+		pipeline.queues[i] = queue
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				outcome, err := p.store(ctx, item)
+				if err != nil {
+					p.storeFailures.Add(1)
+					log.Printf("Error storing port %s: %v", item.port.Key, err)
+				}
+				select {
+				case pipeline.results <- ingestResult{ingestItem: item, outcome: outcome, err: err}:
+				case <-pipeline.abandoned:
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(pipeline.results)
+	}()
+
+	return pipeline
+}
+
+// store persists the Port of item, merging its fields if it has any and
+// checking its expected revision if it has one.
+func (p *PortServiceServer) store(ctx context.Context, item ingestItem) (ports.UpsertOutcome, error) {
+	repo := p.portService.PortForShipsRepository
+	switch {
+	case item.fields != nil && item.expectedRevision != nil:
+		return repo.MergeIfRevision(ctx, item.port, item.fields, *item.expectedRevision)
+	case item.fields != nil:
+		return repo.Merge(ctx, item.port, item.fields)
+	case item.expectedRevision != nil:
+		return repo.UpsertIfRevision(ctx, item.port, *item.expectedRevision)
+	default:
+		return repo.Upsert(ctx, item.port)
+	}
+}
+
+// submit hands item to the worker responsible for its key. It gives up
+// when the stream ends or the worker's queue stays full for longer than the
+// enqueue timeout, returning a gRPC status either way.
+func (pl *ingestPipeline) submit(item ingestItem) error {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(item.port.Key))
+	queue := pl.queues[h.Sum32()%uint32(len(pl.queues))]
+
+	// Fast path, there is room in the queue.
+	select {
+	case queue <- item:
+		return nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if pl.enqueueTimeout > 0 {
+		timer := time.NewTimer(pl.enqueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case queue <- item:
+		return nil
+	case <-pl.ctx.Done():
+		return status.FromContextError(pl.ctx.Err()).Err()
+	case <-timeout:
+		return status.Errorf(codes.ResourceExhausted, "ingest pipeline saturated, port %s not accepted within %s", item.port.Key, pl.enqueueTimeout)
+	}
+}
+
+// reject reports item as failed with err without storing it.
+func (pl *ingestPipeline) reject(item ingestItem, err error) error {
+	select {
+	case pl.results <- ingestResult{ingestItem: item, err: err}:
+		return nil
+	case <-pl.ctx.Done():
+		return status.FromContextError(pl.ctx.Err()).Err()
+	}
+}
+
+func (pl *ingestPipeline) close() {
+	for _, queue := range pl.queues {
+		close(queue)
+	}
+}
+
+// ingestSummary accumulates the IngestSummary of one stream from the
+// results of its pipeline.
+type ingestSummary struct {
+	summary   pb.IngestSummary
+	maxErrors int
+	seen      map[string]struct{}
+	firstErr  error // First rejection.
+	storeErr  error // First rejection caused by the store rather than the Port.
+}
+
+func newIngestSummary(maxErrors int) *ingestSummary {
+	return &ingestSummary{maxErrors: maxErrors, seen: make(map[string]struct{})}
+}
+
+func (s *ingestSummary) add(result ingestResult) {
+	s.summary.Received++
+	if _, ok := s.seen[result.port.Key]; ok {
+		s.summary.Duplicates++
+	}
+	s.seen[result.port.Key] = struct{}{}
+
+	if result.err == nil {
+		s.summary.Stored++
+		switch result.outcome {
+		case ports.Created:
+			s.summary.Created++
+		case ports.Updated:
+			s.summary.Updated++
+		case ports.Unchanged:
+			s.summary.Unchanged++
+		}
+		return
+	}
+
+	s.summary.Rejected++
+	if s.firstErr == nil {
+		s.firstErr = result.err
+	}
+	if s.storeErr == nil && !isPortRejection(result.err) {
+		s.storeErr = result.err
+	}
+	if len(s.summary.Errors) < s.maxErrors {
+		s.summary.Errors = append(s.summary.Errors, &pb.IngestError{
+			Uuid:    result.uuid,
+			Key:     result.port.Key,
+			Message: result.err.Error(),
+			Code:    int32(status.Code(toStatus(result.err))),
+		})
+	}
+}
+
+// response returns the StreamPortsResponse ending the stream. Rejected Ports
+// do not fail the call: ack is false and error and code describe the first
+// one.
+func (s *ingestSummary) response() *pb.StreamPortsResponse {
+	resp := &pb.StreamPortsResponse{Ack: true, Summary: &s.summary}
+	if s.firstErr != nil {
+		resp.Ack = false
+		resp.Error = s.message(s.firstErr)
+		resp.Code = int32(status.Code(toStatus(s.firstErr)))
+	}
+	return resp
+}
+
+// err returns the status failing the call when the store itself failed, nil
+// otherwise. The IngestSummary is attached as a status detail.
+func (s *ingestSummary) err() error {
+	if s.storeErr == nil {
+		return nil
+	}
+	st := status.New(status.Code(toStatus(s.storeErr)), s.message(s.storeErr))
+	if detailed, err := st.WithDetails(&s.summary); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func (s *ingestSummary) message(first error) string {
+	return fmt.Sprintf("%d of %d ports rejected, first: %v", s.summary.Rejected, s.summary.Received, first)
+}
+
+// isPortRejection reports whether err rejects the Port it was returned for,
+// as opposed to the store failing to store it.
+func isPortRejection(err error) bool {
+	switch status.Code(toStatus(err)) {
+	case codes.InvalidArgument, codes.Aborted, codes.FailedPrecondition, codes.NotFound:
+		return true
+	default:
+		return false
+	}
+}
+
+// portReceiver is the receiving half shared by both streaming RPCs.
+type portReceiver interface {
+	Recv() (*pb.StreamPortsRequest, error)
+}
+
+// run feeds the requests of stream into the pipeline and calls handle with
+// the outcome of every one of them. It returns once the client has closed its
+// side and all received Ports have been stored, or right away when receiving
+// fails (the stream was cancelled, its deadline expired or the pipeline
+// stayed saturated) or handle returns an error.
+func (pl *ingestPipeline) run(stream portReceiver, handle func(ingestResult) error) error {
+	recvErr := make(chan error, 1)
+	go func() {
+		recvErr <- pl.receive(stream)
+	}()
+
+	// Workers may still be storing queued Ports after an early return,
+	// make sure they do not block on a result nobody is waiting for.
+	defer close(pl.abandoned)
+
+	results := pl.results
+	for results != nil || recvErr != nil {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			if err := handle(result); err != nil {
+				return err
+			}
+		case err := <-recvErr:
+			if err != nil {
+				return err
+			}
+			recvErr = nil
+		}
+	}
+	return nil
+}
+
+// receive feeds every request of stream into the pipeline until the client
+// closes its side or submitting fails, then closes the pipeline.
+func (pl *ingestPipeline) receive(stream portReceiver) error {
+	defer pl.close()
+
+	for {
+		portData, err := stream.Recv()
+		if err == io.EOF {
+			// End of stream
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		port, err := toDomainPort(portData.GetPort())
+		item := ingestItem{
+			uuid:             portData.GetUuid(),
+			port:             port,
+			fields:           toDomainFields(portData.GetUpdateMask()),
+			expectedRevision: portData.ExpectedRevision,
+		}
+		if err != nil {
+			// Never reaches the repository, report it like a failed store.
+			if err := pl.reject(item, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := pl.submit(item); err != nil {
+			return err
+		}
+	}
+}
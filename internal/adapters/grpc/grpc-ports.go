@@ -0,0 +1,384 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"ports-service/internal/domain"
+	pb "ports-service/internal/gen/grpc"
+	"ports-service/internal/ports"
+)
+
+const (
+	// maxPageSize caps the page size a ListPorts caller can ask for.
+	maxPageSize = 1000
+	// defaultNearbyLimit is the number of Ports SearchNearby returns when
+	// the caller does not set a limit.
+	defaultNearbyLimit = 10
+	// maxNearbyLimit caps the limit a SearchNearby caller can ask for.
+	maxNearbyLimit = 1000
+	// defaultSearchLimit is the number of Ports SearchPorts returns when the
+	// caller does not set a limit.
+	defaultSearchLimit = 10
+	// maxSearchLimit caps the limit a SearchPorts caller can ask for.
+	maxSearchLimit = 100
+)
+
+func (p *PortServiceServer) GetPort(ctx context.Context, req *pb.GetPortRequest) (*pb.GetPortResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key must not be empty")
+	}
+
+	repo := p.portService.PortForShipsRepository
+	var port domain.Port
+	var err error
+	if req.GetAsOf() != nil {
+		if err := req.GetAsOf().CheckValid(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "as_of: %v", err)
+		}
+		port, err = repo.GetPortAsOf(ctx, req.GetKey(), req.GetAsOf().AsTime())
+	} else if req.GetIncludeDeleted() {
+		port, err = repo.GetIncludingDeleted(ctx, req.GetKey())
+	} else {
+		port, err = repo.Get(ctx, req.GetKey())
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.GetPortResponse{Port: toProtoPort(port)}, nil
+}
+
+func (p *PortServiceServer) GetPortHistory(ctx context.Context, req *pb.GetPortHistoryRequest) (*pb.GetPortHistoryResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key must not be empty")
+	}
+
+	revisions, err := p.portService.PortForShipsRepository.GetPortHistory(ctx, req.GetKey())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &pb.GetPortHistoryResponse{Revisions: make([]*pb.PortRevision, 0, len(revisions))}
+	for _, revision := range revisions {
+		resp.Revisions = append(resp.Revisions, toProtoPortRevision(revision))
+	}
+	return resp, nil
+}
+
+func (p *PortServiceServer) ListPorts(ctx context.Context, req *pb.ListPortsRequest) (*pb.ListPortsResponse, error) {
+	if req.GetPageSize() < 0 || req.GetPageSize() > maxPageSize {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must be between 0 and %d", maxPageSize)
+	}
+
+	filter := domain.PortFilter{
+		Country:        req.GetFilter().GetCountry(),
+		City:           req.GetFilter().GetCity(),
+		IncludeDeleted: req.GetFilter().GetIncludeDeleted(),
+	}
+
+	page, err := p.portService.PortForShipsRepository.List(ctx, filter, req.GetPageToken(), int(req.GetPageSize()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &pb.ListPortsResponse{
+		Ports:         make([]*pb.Port, 0, len(page.Items)),
+		NextPageToken: page.NextPageToken,
+	}
+	for _, port := range page.Items {
+		resp.Ports = append(resp.Ports, toProtoPort(port))
+	}
+	return resp, nil
+}
+
+func (p *PortServiceServer) DeletePort(ctx context.Context, req *pb.DeletePortRequest) (*pb.DeletePortResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key must not be empty")
+	}
+
+	repo := p.portService.PortForShipsRepository
+	var err error
+	if req.ExpectedRevision != nil {
+		err = repo.DeleteIfRevision(ctx, req.GetKey(), req.GetExpectedRevision())
+	} else {
+		err = repo.Delete(ctx, req.GetKey())
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.DeletePortResponse{}, nil
+}
+
+func (p *PortServiceServer) RestorePort(ctx context.Context, req *pb.RestorePortRequest) (*pb.RestorePortResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key must not be empty")
+	}
+
+	repo := p.portService.PortForShipsRepository
+	var port domain.Port
+	var err error
+	if req.ExpectedRevision != nil {
+		port, err = repo.RestoreIfRevision(ctx, req.GetKey(), req.GetExpectedRevision())
+	} else {
+		port, err = repo.Restore(ctx, req.GetKey())
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.RestorePortResponse{Port: toProtoPort(port)}, nil
+}
+
+func (p *PortServiceServer) SearchNearby(ctx context.Context, req *pb.SearchNearbyRequest) (*pb.SearchNearbyResponse, error) {
+	if req.GetPoint() == nil {
+		return nil, status.Error(codes.InvalidArgument, "point must be set")
+	}
+	if req.GetLimit() < 0 || req.GetLimit() > maxNearbyLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be between 0 and %d", maxNearbyLimit)
+	}
+	if req.GetMaxDistanceKm() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max_distance_km must not be negative")
+	}
+
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultNearbyLimit
+	}
+	point := domain.GeoPoint{Lat: req.GetPoint().GetLatitude(), Lon: req.GetPoint().GetLongitude()}
+
+	nearby, err := p.portService.PortForShipsRepository.NearestPorts(ctx, point, limit, req.GetMaxDistanceKm())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &pb.SearchNearbyResponse{Ports: make([]*pb.NearbyPort, 0, len(nearby))}
+	for _, match := range nearby {
+		resp.Ports = append(resp.Ports, &pb.NearbyPort{Port: toProtoPort(match.Port), DistanceKm: match.DistanceKm})
+	}
+	return resp, nil
+}
+
+func (p *PortServiceServer) FindPorts(ctx context.Context, req *pb.FindPortsRequest) (*pb.FindPortsResponse, error) {
+	repo := p.portService.PortForShipsRepository
+
+	var found []domain.Port
+	var err error
+	switch query := req.GetQuery().(type) {
+	case *pb.FindPortsRequest_Unloc:
+		found, err = repo.FindByUnloc(ctx, query.Unloc)
+	case *pb.FindPortsRequest_Code:
+		found, err = repo.FindByCode(ctx, query.Code)
+	case *pb.FindPortsRequest_Country:
+		found, err = repo.FindByCountry(ctx, query.Country)
+	case *pb.FindPortsRequest_Alias:
+		found, err = repo.FindByAlias(ctx, query.Alias)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "one of unloc, code, country or alias must be set")
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &pb.FindPortsResponse{Ports: make([]*pb.Port, 0, len(found))}
+	for _, port := range found {
+		resp.Ports = append(resp.Ports, toProtoPort(port))
+	}
+	return resp, nil
+}
+
+func (p *PortServiceServer) SearchPorts(ctx context.Context, req *pb.SearchPortsRequest) (*pb.SearchPortsResponse, error) {
+	if len(domain.SearchTokens(req.GetQuery())) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "query must contain a word")
+	}
+	if req.GetLimit() < 0 || req.GetLimit() > maxSearchLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be between 0 and %d", maxSearchLimit)
+	}
+
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	repo := p.portService.PortForShipsRepository
+	search := repo.SearchPorts
+	if req.GetIncludeDeleted() {
+		search = repo.SearchPortsIncludingDeleted
+	}
+	matches, err := search(ctx, req.GetQuery(), limit)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &pb.SearchPortsResponse{Ports: make([]*pb.PortMatch, 0, len(matches))}
+	for _, match := range matches {
+		resp.Ports = append(resp.Ports, &pb.PortMatch{Port: toProtoPort(match.Port), Score: match.Score})
+	}
+	return resp, nil
+}
+
+func (p *PortServiceServer) WatchPorts(req *pb.WatchPortsRequest, server pb.PortService_WatchPortsServer) error {
+	filter := domain.PortEventFilter{Keys: req.GetKeys(), Country: req.GetCountry()}
+
+	err := p.portService.PortForShipsRepository.WatchPorts(server.Context(), filter, req.GetResumeToken(), func(event domain.PortEvent) error {
+		return server.Send(toProtoPortEvent(event))
+	})
+	if err != nil {
+		return toStatus(err)
+	}
+	return nil
+}
+
+// toStatus maps repository errors onto gRPC status codes.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, ports.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrInvalidPort), errors.Is(err, domain.ErrInvalidGeoPoint):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrRevisionConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, domain.ErrNotDeleted):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, domain.ErrInvalidResumeToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrResumeTokenExpired):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, domain.ErrWatchUnavailable), errors.Is(err, domain.ErrHistoryUnavailable):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// toDomainPort maps a received Port. Its position is taken from location,
+// or else from the [longitude, latitude] coordinates; malformed coordinates
+// yield a *domain.ValidationError.
+func toDomainPort(port *pb.Port) (domain.Port, error) {
+	coordinates, err := toDomainGeoPoint(port)
+	if err != nil {
+		return domain.Port{Key: port.GetKey()}, &domain.ValidationError{
+			Key:    port.GetKey(),
+			Fields: []domain.FieldError{{Field: "coordinates", Reason: err.Error()}},
+		}
+	}
+
+	return domain.Port{
+		Key:         port.GetKey(),
+		Name:        port.GetName(),
+		City:        port.GetCity(),
+		Country:     port.GetCountry(),
+		Alias:       port.GetAlias(),
+		Regions:     port.GetRegions(),
+		Coordinates: coordinates,
+		Province:    port.GetProvince(),
+		Timezone:    port.GetTimezone(),
+		Unlocs:      port.GetUnlocs(),
+		Code:        port.GetCode(),
+	}, nil
+}
+
+// toDomainFields maps the paths of an update mask onto the Port fields they
+// name, or returns nil for an unset or empty mask. Both location and
+// coordinates name the position; unknown paths are passed on for the
+// repository to reject.
+func toDomainFields(mask *fieldmaskpb.FieldMask) []domain.PortField {
+	if len(mask.GetPaths()) == 0 {
+		return nil
+	}
+
+	fields := make([]domain.PortField, 0, len(mask.GetPaths()))
+	for _, path := range mask.GetPaths() {
+		if path == "location" {
+			path = string(domain.FieldCoordinates)
+		}
+		fields = append(fields, domain.PortField(path))
+	}
+	return fields
+}
+
+func toDomainGeoPoint(port *pb.Port) (*domain.GeoPoint, error) {
+	if location := port.GetLocation(); location != nil {
+		return &domain.GeoPoint{Lat: location.GetLatitude(), Lon: location.GetLongitude()}, nil
+	}
+	return domain.GeoPointFromCoordinates(port.GetCoordinates())
+}
+
+func toProtoPort(port domain.Port) *pb.Port {
+	resp := &pb.Port{
+		Key:      port.Key,
+		Name:     port.Name,
+		City:     port.City,
+		Country:  port.Country,
+		Alias:    port.Alias,
+		Regions:  port.Regions,
+		Province: port.Province,
+		Timezone: port.Timezone,
+		Unlocs:   port.Unlocs,
+		Code:     port.Code,
+	}
+	if port.Coordinates != nil {
+		resp.Coordinates = port.Coordinates.Coordinates()
+		resp.Location = toProtoGeoPoint(*port.Coordinates)
+	}
+	if port.UpdatedAt != nil {
+		resp.Revision = port.Revision
+		resp.UpdatedAt = timestamppb.New(*port.UpdatedAt)
+	}
+	if port.DeletedAt != nil {
+		resp.DeletedAt = timestamppb.New(*port.DeletedAt)
+	}
+	return resp
+}
+
+func toProtoPortRevision(revision domain.PortRevision) *pb.PortRevision {
+	resp := &pb.PortRevision{
+		Revision: revision.Revision,
+		Time:     timestamppb.New(revision.Time),
+		Deleted:  revision.Port == nil,
+	}
+	if revision.Port != nil {
+		resp.Port = toProtoPort(*revision.Port)
+	}
+	return resp
+}
+
+var protoUpsertOutcomes = map[ports.UpsertOutcome]pb.UpsertOutcome{
+	ports.Created:   pb.UpsertOutcome_CREATED,
+	ports.Updated:   pb.UpsertOutcome_UPDATED,
+	ports.Unchanged: pb.UpsertOutcome_UNCHANGED,
+}
+
+var protoPortEventTypes = map[domain.PortEventType]pb.PortEvent_Type{
+	domain.PortCreated: pb.PortEvent_CREATED,
+	domain.PortUpdated: pb.PortEvent_UPDATED,
+	domain.PortDeleted: pb.PortEvent_DELETED,
+}
+
+func toProtoPortEvent(event domain.PortEvent) *pb.PortEvent {
+	resp := &pb.PortEvent{
+		Type:        protoPortEventTypes[event.Type],
+		Key:         event.Key,
+		Time:        timestamppb.New(event.Time),
+		ResumeToken: event.ResumeToken,
+	}
+	if event.Old != nil {
+		resp.OldPort = toProtoPort(*event.Old)
+	}
+	if event.New != nil {
+		resp.NewPort = toProtoPort(*event.New)
+	}
+	return resp
+}
+
+func toProtoGeoPoint(point domain.GeoPoint) *pb.GeoPoint {
+	return &pb.GeoPoint{Latitude: point.Lat, Longitude: point.Lon}
+}
@@ -0,0 +1,397 @@
+package grpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"ports-service/internal/adapters/database"
+	grpcadapter "ports-service/internal/adapters/grpc"
+	"ports-service/internal/domain"
+	pb "ports-service/internal/gen/grpc"
+)
+
+// testConfig is the pipeline configuration used by newTestClient.
+var testConfig = grpcadapter.Config{BufferSize: 10, Workers: 4}
+
+// newTestClient serves a PortServiceServer backed by repo over an in-memory
+// listener and returns a client connected to it.
+func newTestClient(t *testing.T, repo domain.PortRepository) pb.PortServiceClient {
+	t.Helper()
+	_, client := newTestServer(t, repo, testConfig)
+	return client
+}
+
+// newTestServer is like newTestClient but takes the pipeline configuration
+// and also returns the server.
+func newTestServer(t *testing.T, repo domain.PortRepository, config grpcadapter.Config) (*grpcadapter.PortServiceServer, pb.PortServiceClient) {
+	t.Helper()
+
+	portService := grpcadapter.PortService{PortForShipsRepository: repo}
+	server := grpcadapter.NewPortServiceServer(portService, config)
+	_, client := serve(t, server)
+	return server, client
+}
+
+// serve registers server with a gRPC server listening in memory and returns
+// that gRPC server and a client connected to it.
+func serve(t *testing.T, server pb.PortServiceServer) (*grpc.Server, pb.PortServiceClient) {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterPortServiceServer(s, server)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return s, pb.NewPortServiceClient(conn)
+}
+
+func newTestRepository(t *testing.T, seed ...domain.Port) domain.StorePortRepository {
+	t.Helper()
+	repo := domain.StorePortRepository{Data: database.NewMemDB[domain.Port]()}
+	for _, port := range seed {
+		require.NoError(t, repo.Store(context.Background(), port))
+	}
+	return repo
+}
+
+var (
+	rotterdam = domain.Port{
+		Key:         "NLRTM",
+		Name:        "Rotterdam",
+		City:        "Rotterdam",
+		Country:     "Netherlands",
+		Coordinates: &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917},
+		Timezone:    "Europe/Amsterdam",
+		Unlocs:      []string{"NLRTM"},
+		Code:        "42157",
+	}
+	hamburg = domain.Port{
+		Key:         "DEHAM",
+		Name:        "Hamburg",
+		City:        "Hamburg",
+		Country:     "Germany",
+		Coordinates: &domain.GeoPoint{Lat: 53.551085, Lon: 9.993682},
+		Timezone:    "Europe/Berlin",
+		Unlocs:      []string{"DEHAM"},
+		Code:        "42861",
+	}
+)
+
+func TestGetPort(t *testing.T) {
+	client := newTestClient(t, newTestRepository(t, rotterdam))
+	ctx := context.Background()
+
+	resp, err := client.GetPort(ctx, &pb.GetPortRequest{Key: "NLRTM"})
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam", resp.GetPort().GetName())
+	assert.Equal(t, []float64{4.47917, 51.9225}, resp.GetPort().GetCoordinates())
+	assert.Equal(t, 51.9225, resp.GetPort().GetLocation().GetLatitude())
+	assert.Equal(t, 4.47917, resp.GetPort().GetLocation().GetLongitude())
+
+	_, err = client.GetPort(ctx, &pb.GetPortRequest{Key: "DEHAM"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetPort(ctx, &pb.GetPortRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGetPortHistory(t *testing.T) {
+	clock := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo := domain.StorePortRepository{
+		Data:    database.NewMemDB[domain.Port](),
+		History: database.NewHistoryStore(database.NewMemDB[[]domain.PortRevision]()),
+		Now: func() time.Time {
+			clock = clock.Add(time.Hour)
+			return clock
+		},
+	}
+	client := newTestClient(t, repo)
+	ctx := context.Background()
+
+	renamed := rotterdam
+	renamed.Name = "Rotterdam Europoort"
+	require.NoError(t, repo.Store(ctx, rotterdam))
+	require.NoError(t, repo.Store(ctx, renamed))
+	require.NoError(t, repo.Delete(ctx, "NLRTM"))
+
+	resp, err := client.GetPortHistory(ctx, &pb.GetPortHistoryRequest{Key: "NLRTM"})
+	require.NoError(t, err)
+	require.Len(t, resp.GetRevisions(), 3)
+	first := resp.GetRevisions()[0]
+	assert.Equal(t, uint64(1), first.GetRevision())
+	assert.Equal(t, "Rotterdam", first.GetPort().GetName())
+	assert.Equal(t, uint64(1), first.GetPort().GetRevision())
+	assert.Equal(t, first.GetTime().AsTime(), first.GetPort().GetUpdatedAt().AsTime())
+	assert.False(t, first.GetDeleted())
+	assert.True(t, resp.GetRevisions()[2].GetDeleted())
+	assert.Nil(t, resp.GetRevisions()[2].GetPort())
+
+	asOf, err := client.GetPort(ctx, &pb.GetPortRequest{Key: "NLRTM", AsOf: resp.GetRevisions()[1].GetTime()})
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam Europoort", asOf.GetPort().GetName())
+	assert.Equal(t, uint64(2), asOf.GetPort().GetRevision())
+
+	_, err = client.GetPort(ctx, &pb.GetPortRequest{Key: "NLRTM", AsOf: timestamppb.New(clock)})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.GetPort(ctx, &pb.GetPortRequest{Key: "NLRTM", AsOf: &timestamppb.Timestamp{Nanos: -1}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.GetPortHistory(ctx, &pb.GetPortHistoryRequest{Key: "DEHAM"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Without a history store, history is not served.
+	_, err = newTestClient(t, newTestRepository(t, rotterdam)).GetPortHistory(ctx, &pb.GetPortHistoryRequest{Key: "NLRTM"})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestListPorts(t *testing.T) {
+	client := newTestClient(t, newTestRepository(t, rotterdam, hamburg))
+	ctx := context.Background()
+
+	resp, err := client.ListPorts(ctx, &pb.ListPortsRequest{PageSize: 1})
+	require.NoError(t, err)
+	require.Len(t, resp.GetPorts(), 1)
+	assert.Equal(t, "DEHAM", resp.GetPorts()[0].GetKey())
+	assert.NotEmpty(t, resp.GetNextPageToken())
+
+	resp, err = client.ListPorts(ctx, &pb.ListPortsRequest{PageSize: 1, PageToken: resp.GetNextPageToken()})
+	require.NoError(t, err)
+	require.Len(t, resp.GetPorts(), 1)
+	assert.Equal(t, "NLRTM", resp.GetPorts()[0].GetKey())
+	assert.Empty(t, resp.GetNextPageToken())
+
+	resp, err = client.ListPorts(ctx, &pb.ListPortsRequest{Filter: &pb.PortFilter{Country: "germany"}})
+	require.NoError(t, err)
+	require.Len(t, resp.GetPorts(), 1)
+	assert.Equal(t, "DEHAM", resp.GetPorts()[0].GetKey())
+
+	_, err = client.ListPorts(ctx, &pb.ListPortsRequest{PageSize: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestDeletePort(t *testing.T) {
+	client := newTestClient(t, newTestRepository(t, rotterdam))
+	ctx := context.Background()
+
+	_, err := client.DeletePort(ctx, &pb.DeletePortRequest{Key: "NLRTM"})
+	require.NoError(t, err)
+
+	_, err = client.GetPort(ctx, &pb.GetPortRequest{Key: "NLRTM"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.DeletePort(ctx, &pb.DeletePortRequest{Key: "NLRTM"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.DeletePort(ctx, &pb.DeletePortRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestRestorePort(t *testing.T) {
+	client := newTestClient(t, newTestRepository(t, rotterdam, hamburg))
+	ctx := context.Background()
+
+	stale := uint64(0)
+	_, err := client.DeletePort(ctx, &pb.DeletePortRequest{Key: "NLRTM", ExpectedRevision: &stale})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	current := uint64(1)
+	_, err = client.DeletePort(ctx, &pb.DeletePortRequest{Key: "NLRTM", ExpectedRevision: &current})
+	require.NoError(t, err)
+
+	// Deleted Ports are only read when asked for.
+	deleted, err := client.GetPort(ctx, &pb.GetPortRequest{Key: "NLRTM", IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.NotNil(t, deleted.GetPort().GetDeletedAt())
+	list, err := client.ListPorts(ctx, &pb.ListPortsRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetPorts(), 1)
+	assert.Equal(t, "DEHAM", list.GetPorts()[0].GetKey())
+	list, err = client.ListPorts(ctx, &pb.ListPortsRequest{Filter: &pb.PortFilter{IncludeDeleted: true}})
+	require.NoError(t, err)
+	assert.Len(t, list.GetPorts(), 2)
+	search, err := client.SearchPorts(ctx, &pb.SearchPortsRequest{Query: "rotterdam"})
+	require.NoError(t, err)
+	assert.Empty(t, search.GetPorts())
+	search, err = client.SearchPorts(ctx, &pb.SearchPortsRequest{Query: "rotterdam", IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Len(t, search.GetPorts(), 1)
+
+	_, err = client.RestorePort(ctx, &pb.RestorePortRequest{Key: "NLRTM", ExpectedRevision: &current})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	restored, err := client.RestorePort(ctx, &pb.RestorePortRequest{Key: "NLRTM"})
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam", restored.GetPort().GetName())
+	assert.Nil(t, restored.GetPort().GetDeletedAt())
+	assert.Equal(t, uint64(3), restored.GetPort().GetRevision())
+	_, err = client.GetPort(ctx, &pb.GetPortRequest{Key: "NLRTM"})
+	require.NoError(t, err)
+
+	_, err = client.RestorePort(ctx, &pb.RestorePortRequest{Key: "NLRTM"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.RestorePort(ctx, &pb.RestorePortRequest{Key: "BEANR"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.RestorePort(ctx, &pb.RestorePortRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSearchNearby(t *testing.T) {
+	client := newTestClient(t, newTestRepository(t, rotterdam, hamburg))
+	ctx := context.Background()
+
+	resp, err := client.SearchNearby(ctx, &pb.SearchNearbyRequest{
+		Point: &pb.GeoPoint{Latitude: 53.5, Longitude: 10},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.GetPorts(), 2)
+	assert.Equal(t, "DEHAM", resp.GetPorts()[0].GetPort().GetKey())
+	assert.Equal(t, "NLRTM", resp.GetPorts()[1].GetPort().GetKey())
+	assert.Less(t, resp.GetPorts()[0].GetDistanceKm(), resp.GetPorts()[1].GetDistanceKm())
+
+	resp, err = client.SearchNearby(ctx, &pb.SearchNearbyRequest{
+		Point:         &pb.GeoPoint{Latitude: 53.5, Longitude: 10},
+		Limit:         5,
+		MaxDistanceKm: 50,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.GetPorts(), 1)
+	assert.Equal(t, "DEHAM", resp.GetPorts()[0].GetPort().GetKey())
+
+	testCases := []struct {
+		name string
+		req  *pb.SearchNearbyRequest
+	}{
+		{name: "MissingPoint", req: &pb.SearchNearbyRequest{}},
+		{name: "LatitudeOutOfRange", req: &pb.SearchNearbyRequest{Point: &pb.GeoPoint{Latitude: 95}}},
+		{name: "NegativeLimit", req: &pb.SearchNearbyRequest{Point: &pb.GeoPoint{}, Limit: -1}},
+		{name: "NegativeDistance", req: &pb.SearchNearbyRequest{Point: &pb.GeoPoint{}, MaxDistanceKm: -1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.SearchNearby(ctx, tc.req)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func TestFindPorts(t *testing.T) {
+	client := newTestClient(t, newTestRepository(t, rotterdam, hamburg))
+	ctx := context.Background()
+
+	testCases := []struct {
+		name string
+		req  *pb.FindPortsRequest
+		want []string
+	}{
+		{name: "Unloc", req: &pb.FindPortsRequest{Query: &pb.FindPortsRequest_Unloc{Unloc: "DEHAM"}}, want: []string{"DEHAM"}},
+		{name: "Code", req: &pb.FindPortsRequest{Query: &pb.FindPortsRequest_Code{Code: "42157"}}, want: []string{"NLRTM"}},
+		{name: "Country", req: &pb.FindPortsRequest{Query: &pb.FindPortsRequest_Country{Country: "germany"}}, want: []string{"DEHAM"}},
+		{name: "NoMatch", req: &pb.FindPortsRequest{Query: &pb.FindPortsRequest_Alias{Alias: "Mokum"}}, want: []string{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := client.FindPorts(ctx, tc.req)
+			require.NoError(t, err)
+			keys := []string{}
+			for _, port := range resp.GetPorts() {
+				keys = append(keys, port.GetKey())
+			}
+			assert.Equal(t, tc.want, keys)
+		})
+	}
+
+	_, err := client.FindPorts(ctx, &pb.FindPortsRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSearchPorts(t *testing.T) {
+	client := newTestClient(t, newTestRepository(t, rotterdam, hamburg))
+	ctx := context.Background()
+
+	resp, err := client.SearchPorts(ctx, &pb.SearchPortsRequest{Query: "rotterdm"})
+	require.NoError(t, err)
+	require.Len(t, resp.GetPorts(), 1)
+	assert.Equal(t, "NLRTM", resp.GetPorts()[0].GetPort().GetKey())
+	assert.Greater(t, resp.GetPorts()[0].GetScore(), 0.0)
+
+	resp, err = client.SearchPorts(ctx, &pb.SearchPortsRequest{Query: "lisbon"})
+	require.NoError(t, err)
+	assert.Empty(t, resp.GetPorts())
+
+	_, err = client.SearchPorts(ctx, &pb.SearchPortsRequest{Query: " - "})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.SearchPorts(ctx, &pb.SearchPortsRequest{Query: "rotterdam", Limit: 1000})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestWatchPorts(t *testing.T) {
+	events := database.NewEventLog(0)
+	repo := domain.StorePortRepository{Data: database.NewMemDB[domain.Port](events), Events: events}
+	client := newTestClient(t, repo)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := events.ResumeToken()
+	require.NoError(t, repo.Store(ctx, rotterdam))
+	require.NoError(t, repo.Store(ctx, hamburg))
+	moved := rotterdam
+	moved.Country = "Belgium"
+	require.NoError(t, repo.Store(ctx, moved))
+	require.NoError(t, repo.Delete(ctx, "NLRTM"))
+
+	// Rotterdam leaving the Netherlands is still reported.
+	stream, err := client.WatchPorts(ctx, &pb.WatchPortsRequest{Country: "netherlands", ResumeToken: start})
+	require.NoError(t, err)
+	created, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, pb.PortEvent_CREATED, created.GetType())
+	assert.Equal(t, "NLRTM", created.GetKey())
+	assert.Nil(t, created.GetOldPort())
+	assert.Equal(t, "Netherlands", created.GetNewPort().GetCountry())
+	assert.NotNil(t, created.GetTime())
+	updated, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, pb.PortEvent_UPDATED, updated.GetType())
+	assert.Equal(t, "Netherlands", updated.GetOldPort().GetCountry())
+	assert.Equal(t, "Belgium", updated.GetNewPort().GetCountry())
+
+	// Resuming after the update only yields the deletion of NLRTM.
+	stream, err = client.WatchPorts(ctx, &pb.WatchPortsRequest{Keys: []string{"NLRTM"}, ResumeToken: updated.GetResumeToken()})
+	require.NoError(t, err)
+	deleted, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, pb.PortEvent_DELETED, deleted.GetType())
+	assert.Equal(t, "Belgium", deleted.GetOldPort().GetCountry())
+	assert.Nil(t, deleted.GetNewPort())
+
+	stream, err = client.WatchPorts(ctx, &pb.WatchPortsRequest{ResumeToken: "garbage"})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stream, err = client.WatchPorts(ctx, &pb.WatchPortsRequest{ResumeToken: database.NewEventLog(0).ResumeToken()})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.OutOfRange, status.Code(err))
+}
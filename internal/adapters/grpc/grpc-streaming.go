@@ -1,16 +1,16 @@
 package grpc
 
 import (
-	"context"
-	"io"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 
 	"ports-service/internal/domain"
 	pb "ports-service/internal/gen/grpc"
@@ -18,76 +18,78 @@ import (
 
 type PortServiceServer struct {
 	pb.UnimplementedPortServiceServer
-	grpcStreamChan chan domain.Port // Channel for streaming data
-	portService    PortService
+	portService   PortService
+	config        Config
+	storeFailures atomic.Uint64 // Ports that could not be stored, across all streams.
 }
 
-func NewPortServiceServer(portService PortService, grpcStreamChan chan domain.Port) *PortServiceServer {
+func NewPortServiceServer(portService PortService, config Config) *PortServiceServer {
 	return &PortServiceServer{
-		portService:    portService,
-		grpcStreamChan: grpcStreamChan,
+		portService: portService,
+		config:      config.withDefaults(),
 	}
 }
 
-func (p *PortServiceServer) StreamPorts(server pb.PortService_StreamPortsServer) error {
-	for {
-		portData, err := server.Recv()
-		if err == io.EOF {
-			// End of stream
-			return nil
-		}
-		if err != nil {
-			return err // Handle the error appropriately
-		}
+// StoreFailures returns how many received Ports could not be stored since
+// the server started.
+func (p *PortServiceServer) StoreFailures() uint64 {
+	return p.storeFailures.Load()
+}
 
-		port := domain.Port{
-			Key:         portData.Port.Key,
-			Name:        portData.Port.Name,
-			City:        portData.Port.City,
-			Country:     portData.Port.Country,
-			Alias:       portData.Port.Alias,
-			Regions:     portData.Port.Regions,
-			Coordinates: portData.Port.Coordinates,
-			Province:    portData.Port.Province,
-			Timezone:    portData.Port.Timezone,
-			Unlocs:      portData.Port.Unlocs,
-			Code:        portData.Port.Code,
-		}
+// StreamPorts runs its own ingest pipeline for the received Ports and only
+// returns once every one of them has been stored. It then answers with an
+// IngestSummary of the call, which lists the first Config.MaxErrorDetails
+// Ports that could not be stored; the response is not acked if there are
+// any. When the store failed on one of them, the call fails with that
+// error's code instead and carries the IngestSummary as a status detail.
+// The call ends early when the stream is cancelled or its deadline expires,
+// and with codes.ResourceExhausted when the pipeline stays saturated for
+// longer than Config.EnqueueTimeout.
+func (p *PortServiceServer) StreamPorts(server pb.PortService_StreamPortsServer) error {
+	pipeline := p.newPipeline(server.Context())
+	summary := newIngestSummary(p.config.MaxErrorDetails)
 
-		// Process received data
-		p.grpcStreamChan <- port // Assuming the types match
+	err := pipeline.run(server, func(result ingestResult) error {
+		summary.add(result)
+		return nil
+	})
+	if err != nil {
+		return err // Handle the error appropriately
 	}
-}
 
-func (p *PortService) StreamFromGRPC(ctx context.Context, bufferSize int, grpcStreamChan chan domain.Port) error {
-	streamer := NewStreamer[domain.Port](grpcStreamChan)
-	portStream, err := streamer.StreamObjects(ctx, bufferSize)
-	if err != nil {
+	log.Printf("StreamPorts finished: received %d, stored %d (created %d, updated %d, unchanged %d), rejected %d, duplicates %d",
+		summary.summary.Received, summary.summary.Stored, summary.summary.Created, summary.summary.Updated,
+		summary.summary.Unchanged, summary.summary.Rejected, summary.summary.Duplicates)
+
+	if err := summary.err(); err != nil {
 		return err
 	}
+	return server.SendAndClose(summary.response())
+}
 
-	for port := range portStream {
-		err := p.PortForShipsRepository.Store(ctx, port)
-		if err != nil {
-			return err
+// StreamPortsBidi stores the received Ports through its own ingest pipeline
+// and acknowledges each one with a response carrying the request uuid. A Port
+// that cannot be stored is answered with ack set to false and the error, so
+// clients can retry just the failed items; the stream itself keeps going.
+// Acknowledgements are sent as Ports get stored, which is not necessarily
+// the order they were received in.
+func (p *PortServiceServer) StreamPortsBidi(server pb.PortService_StreamPortsBidiServer) error {
+	pipeline := p.newPipeline(server.Context())
+
+	return pipeline.run(server, func(result ingestResult) error {
+		resp := &pb.StreamPortsResponse{Uuid: result.uuid, Ack: true, Outcome: protoUpsertOutcomes[result.outcome]}
+		if result.err != nil {
+			resp.Ack = false
+			resp.Error = result.err.Error()
+			resp.Code = int32(status.Code(toStatus(result.err)))
 		}
-	}
-	return nil
+		return server.Send(resp)
+	})
 }
 
-func StartServer(address string, portService PortService, bufferzise int) error {
-	// Create a channel for streaming data from the gRPC handler
-	grpcStreamChan := make(chan domain.Port)
-
-	// Create a new PortServiceServer with the channel and PortService
-	server := NewPortServiceServer(portService, grpcStreamChan)
-
-	// Start the streaming process
-	go func() {
-		if err := portService.StreamFromGRPC(context.Background(), bufferzise, grpcStreamChan); err != nil {
-			log.Fatalf("Error streaming ports: %v", err)
-		}
-	}()
+func StartServer(address string, portService PortService, config Config) error {
+	// Create a new PortServiceServer with the PortService
+	server := NewPortServiceServer(portService, config)
 
 	// Listen on the specified address
 	lis, err := net.Listen("tcp", address)
@@ -139,34 +141,5 @@ func StartServer(address string, portService PortService, bufferzise int) error
 }
 
 type PortService struct {
-	PortForShipsRepository domain.StorePortRepository
-}
-
-// Streamer is a generic type for streaming data from a JSON file.
-// T is the type of data that will be streamed.
-type Streamer[T any] struct {
-	grpcStream <-chan T
-}
-
-func NewStreamer[T any](grpcStream <-chan T) *Streamer[T] {
-	return &Streamer[T]{grpcStream: grpcStream}
-}
-
-func (s *Streamer[T]) StreamObjects(ctx context.Context, bufferSize int) (<-chan T, error) {
-	ch := make(chan T, bufferSize)
-	go func() {
-		defer close(ch)
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case item, ok := <-s.grpcStream:
-				if !ok {
-					return // gRPC stream closed
-				}
-				ch <- item
-			}
-		}
-	}()
-	return ch, nil
+	PortForShipsRepository domain.PortRepository
 }
@@ -1,3 +1,454 @@
 package grpc_test
 
-// TODO: test server with Bufconn google.golang.org/grpc/test/bufconn
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	grpcadapter "ports-service/internal/adapters/grpc"
+	"ports-service/internal/domain"
+	pb "ports-service/internal/gen/grpc"
+	"ports-service/internal/ports"
+)
+
+var errStoreFailed = errors.New("store failed")
+
+// failingRepository refuses to store the Ports whose key is listed in fail.
+type failingRepository struct {
+	domain.StorePortRepository
+	fail map[string]bool
+}
+
+func (r failingRepository) Upsert(ctx context.Context, port domain.Port) (ports.UpsertOutcome, error) {
+	if r.fail[port.Key] {
+		return 0, errStoreFailed
+	}
+	return r.StorePortRepository.Upsert(ctx, port)
+}
+
+func TestStreamPortsBidi(t *testing.T) {
+	repo := failingRepository{
+		StorePortRepository: newTestRepository(t),
+		fail:                map[string]bool{"DEHAM": true},
+	}
+	client := newTestClient(t, repo)
+	ctx := context.Background()
+
+	stream, err := client.StreamPortsBidi(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "1", Port: toProto(rotterdam)}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "1", resp.GetUuid())
+	assert.True(t, resp.GetAck())
+	assert.Empty(t, resp.GetError())
+	assert.Equal(t, pb.UpsertOutcome_CREATED, resp.GetOutcome())
+
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "2", Port: toProto(hamburg)}))
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "2", resp.GetUuid())
+	assert.False(t, resp.GetAck())
+	assert.Contains(t, resp.GetError(), errStoreFailed.Error())
+
+	require.NoError(t, stream.CloseSend())
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+
+	stored, err := repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), stored.Revision)
+	assert.True(t, rotterdam.Equal(stored), "%+v", stored)
+}
+
+func TestStreamPorts(t *testing.T) {
+	client := newTestClient(t, newTestRepository(t))
+	ctx := context.Background()
+
+	stream, err := client.StreamPorts(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "1", Port: toProto(rotterdam)}))
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "2", Port: toProto(hamburg)}))
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "3", Port: toProto(hamburg)}))
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.True(t, resp.GetAck())
+	assert.Equal(t, uint64(3), resp.GetSummary().GetReceived())
+	assert.Equal(t, uint64(3), resp.GetSummary().GetStored())
+	assert.Equal(t, uint64(0), resp.GetSummary().GetRejected())
+	assert.Equal(t, uint64(1), resp.GetSummary().GetDuplicates())
+	assert.Equal(t, uint64(2), resp.GetSummary().GetCreated())
+	assert.Equal(t, uint64(0), resp.GetSummary().GetUpdated())
+	assert.Equal(t, uint64(1), resp.GetSummary().GetUnchanged())
+	assert.Empty(t, resp.GetSummary().GetErrors())
+
+	// StreamPorts only returns once every received port has been stored.
+	stored, err := client.GetPort(ctx, &pb.GetPortRequest{Key: "DEHAM"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hamburg", stored.GetPort().GetName())
+}
+
+func TestStreamPorts_StoreFailure(t *testing.T) {
+	repo := failingRepository{
+		StorePortRepository: newTestRepository(t),
+		fail:                map[string]bool{"DEHAM": true},
+	}
+	server, client := newTestServer(t, repo, testConfig)
+	ctx := context.Background()
+
+	failing, err := client.StreamPorts(ctx)
+	require.NoError(t, err)
+	require.NoError(t, failing.Send(&pb.StreamPortsRequest{Uuid: "1", Port: toProto(hamburg)}))
+	require.NoError(t, failing.Send(&pb.StreamPortsRequest{Uuid: "2", Port: toProto(rotterdam)}))
+	_, err = failing.CloseAndRecv()
+	assert.Equal(t, codes.Internal, status.Code(err), "a store failure fails the call")
+	assert.Contains(t, status.Convert(err).Message(), errStoreFailed.Error())
+	assert.Equal(t, uint64(1), server.StoreFailures())
+
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	summary, ok := details[0].(*pb.IngestSummary)
+	require.True(t, ok, "status detail should be an IngestSummary, got %T", details[0])
+	assert.Equal(t, uint64(2), summary.GetReceived())
+	assert.Equal(t, uint64(1), summary.GetStored())
+	assert.Equal(t, uint64(1), summary.GetRejected())
+	require.Len(t, summary.GetErrors(), 1)
+	assert.Equal(t, "1", summary.GetErrors()[0].GetUuid())
+	assert.Equal(t, "DEHAM", summary.GetErrors()[0].GetKey())
+	assert.Contains(t, summary.GetErrors()[0].GetMessage(), errStoreFailed.Error())
+	assert.Equal(t, int32(codes.Internal), summary.GetErrors()[0].GetCode())
+
+	// The server keeps serving other streams after a store failure.
+	healthy, err := client.StreamPorts(ctx)
+	require.NoError(t, err)
+	require.NoError(t, healthy.Send(&pb.StreamPortsRequest{Uuid: "2", Port: toProto(rotterdam)}))
+	resp, err := healthy.CloseAndRecv()
+	require.NoError(t, err)
+	assert.True(t, resp.GetAck())
+
+	_, err = repo.Get(ctx, "NLRTM")
+	assert.NoError(t, err)
+}
+
+func TestStreamPorts_Coordinates(t *testing.T) {
+	repo := newTestRepository(t)
+	client := newTestClient(t, repo)
+	ctx := context.Background()
+
+	legacy := toProto(rotterdam)
+	legacy.Location = nil
+	legacy.Coordinates = []float64{4.47917, 51.9225}
+
+	stream, err := client.StreamPorts(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "1", Port: legacy}))
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "2", Port: toProto(hamburg)}))
+	_, err = stream.CloseAndRecv()
+	require.NoError(t, err)
+
+	stored, err := repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}, stored.Coordinates)
+
+	stored, err = repo.Get(ctx, "DEHAM")
+	require.NoError(t, err)
+	assert.Equal(t, hamburg.Coordinates, stored.Coordinates)
+}
+
+func TestStreamPorts_MaxErrorDetails(t *testing.T) {
+	repo := failingRepository{
+		StorePortRepository: newTestRepository(t),
+		fail:                map[string]bool{"DEHAM": true},
+	}
+	config := grpcadapter.Config{BufferSize: 10, Workers: 1, MaxErrorDetails: 2}
+	_, client := newTestServer(t, repo, config)
+
+	stream, err := client.StreamPorts(context.Background())
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: fmt.Sprint(i), Port: toProto(hamburg)}))
+	}
+	_, err = stream.CloseAndRecv()
+	require.Error(t, err)
+
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	summary := details[0].(*pb.IngestSummary)
+	assert.Equal(t, uint64(5), summary.GetRejected())
+	assert.Equal(t, uint64(4), summary.GetDuplicates())
+	assert.Len(t, summary.GetErrors(), 2)
+}
+
+func TestStreamPorts_InvalidPort(t *testing.T) {
+	client := newTestClient(t, newTestRepository(t))
+	ctx := context.Background()
+
+	invalid := toProto(rotterdam)
+	invalid.Location = nil
+	invalid.Coordinates = []float64{4.47917}
+
+	stream, err := client.StreamPorts(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "1", Port: invalid}))
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "2", Port: toProto(hamburg)}))
+	summaryResp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, int32(codes.InvalidArgument), summaryResp.GetCode())
+	assert.Contains(t, summaryResp.GetError(), "coordinates")
+	summary := summaryResp.GetSummary()
+	assert.Equal(t, uint64(1), summary.GetRejected())
+	assert.Equal(t, uint64(1), summary.GetStored())
+	assert.Equal(t, "NLRTM", summary.GetErrors()[0].GetKey())
+	assert.Equal(t, int32(codes.InvalidArgument), summary.GetErrors()[0].GetCode())
+
+	bidi, err := client.StreamPortsBidi(ctx)
+	require.NoError(t, err)
+	require.NoError(t, bidi.Send(&pb.StreamPortsRequest{Uuid: "3", Port: invalid}))
+	resp, err := bidi.Recv()
+	require.NoError(t, err)
+	assert.False(t, resp.GetAck())
+	assert.Contains(t, resp.GetError(), domain.ErrInvalidPort.Error())
+	require.NoError(t, bidi.CloseSend())
+
+	_, err = client.GetPort(ctx, &pb.GetPortRequest{Key: "NLRTM"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// blockingRepository holds back storing the Ports whose key is listed in
+// block until release is closed.
+type blockingRepository struct {
+	domain.StorePortRepository
+	block   map[string]bool
+	release chan struct{}
+}
+
+func (r blockingRepository) Upsert(ctx context.Context, port domain.Port) (ports.UpsertOutcome, error) {
+	if r.block[port.Key] {
+		<-r.release
+	}
+	return r.StorePortRepository.Upsert(ctx, port)
+}
+
+func TestStreamPorts_StreamsDoNotBlockEachOther(t *testing.T) {
+	repo := blockingRepository{
+		StorePortRepository: newTestRepository(t),
+		block:               map[string]bool{"DEHAM": true},
+		release:             make(chan struct{}),
+	}
+	client := newTestClient(t, repo)
+	ctx := context.Background()
+
+	slow, err := client.StreamPorts(ctx)
+	require.NoError(t, err)
+	require.NoError(t, slow.Send(&pb.StreamPortsRequest{Uuid: "1", Port: toProto(hamburg)}))
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := slow.CloseAndRecv()
+		slowDone <- err
+	}()
+
+	fast, err := client.StreamPorts(ctx)
+	require.NoError(t, err)
+	require.NoError(t, fast.Send(&pb.StreamPortsRequest{Uuid: "2", Port: toProto(rotterdam)}))
+	_, err = fast.CloseAndRecv()
+	require.NoError(t, err)
+
+	select {
+	case <-slowDone:
+		t.Fatal("slow stream returned before its port was stored")
+	default:
+	}
+
+	close(repo.release)
+	require.NoError(t, <-slowDone)
+
+	_, err = repo.Get(ctx, "DEHAM")
+	assert.NoError(t, err)
+}
+
+func TestStreamPorts_KeepsOrderPerKey(t *testing.T) {
+	repo := newTestRepository(t)
+	client := newTestClient(t, repo)
+	ctx := context.Background()
+
+	stream, err := client.StreamPorts(ctx)
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		port := toProto(rotterdam)
+		port.Name = fmt.Sprintf("Rotterdam %d", i)
+		require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: fmt.Sprint(i), Port: port}))
+		require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: fmt.Sprint(i), Port: toProto(hamburg)}))
+	}
+	_, err = stream.CloseAndRecv()
+	require.NoError(t, err)
+
+	stored, err := repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam 99", stored.Name)
+}
+
+func TestStreamPorts_PipelineSaturated(t *testing.T) {
+	repo := blockingRepository{
+		StorePortRepository: newTestRepository(t),
+		block:               map[string]bool{"DEHAM": true},
+		release:             make(chan struct{}),
+	}
+	defer close(repo.release)
+	config := grpcadapter.Config{BufferSize: 1, Workers: 1, EnqueueTimeout: 20 * time.Millisecond}
+	_, client := newTestServer(t, repo, config)
+
+	stream, err := client.StreamPorts(context.Background())
+	require.NoError(t, err)
+	// One port is being stored, one is queued, the rest cannot get in.
+	for i := 0; i < 5; i++ {
+		if err := stream.Send(&pb.StreamPortsRequest{Uuid: fmt.Sprint(i), Port: toProto(hamburg)}); err != nil {
+			break // The server may already have given up on the stream.
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestStreamPorts_ClientCancellation(t *testing.T) {
+	repo := blockingRepository{
+		StorePortRepository: newTestRepository(t),
+		block:               map[string]bool{"DEHAM": true},
+		release:             make(chan struct{}),
+	}
+	defer close(repo.release)
+	s, client := serve(t, grpcadapter.NewPortServiceServer(
+		grpcadapter.PortService{PortForShipsRepository: repo},
+		grpcadapter.Config{BufferSize: 1, Workers: 1},
+	))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.StreamPorts(ctx)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: fmt.Sprint(i), Port: toProto(hamburg)}))
+	}
+	cancel()
+
+	// GracefulStop waits for running handlers, so it only returns if the
+	// handler noticed the cancellation despite the blocked pipeline.
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("StreamPorts handler did not return after the client cancelled")
+	}
+}
+
+func toProto(port domain.Port) *pb.Port {
+	resp := &pb.Port{
+		Key:      port.Key,
+		Name:     port.Name,
+		City:     port.City,
+		Country:  port.Country,
+		Alias:    port.Alias,
+		Regions:  port.Regions,
+		Province: port.Province,
+		Timezone: port.Timezone,
+		Unlocs:   port.Unlocs,
+		Code:     port.Code,
+	}
+	if port.Coordinates != nil {
+		resp.Location = &pb.GeoPoint{Latitude: port.Coordinates.Lat, Longitude: port.Coordinates.Lon}
+	}
+	return resp
+}
+
+func TestStreamPortsBidi_UpdateMask(t *testing.T) {
+	repo := newTestRepository(t, rotterdam)
+	client := newTestClient(t, repo)
+	ctx := context.Background()
+
+	stream, err := client.StreamPortsBidi(ctx)
+	require.NoError(t, err)
+	patch := &pb.Port{Key: "NLRTM", Location: &pb.GeoPoint{Latitude: 51.95, Longitude: 4.14}}
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{
+		Uuid:       "1",
+		Port:       patch,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"location"}},
+	}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.True(t, resp.GetAck(), resp.GetError())
+	assert.Equal(t, pb.UpsertOutcome_UPDATED, resp.GetOutcome())
+
+	// Fields missing from the mask are kept.
+	stored, err := repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam", stored.Name)
+	assert.Equal(t, &domain.GeoPoint{Lat: 51.95, Lon: 4.14}, stored.Coordinates)
+
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{
+		Uuid:       "2",
+		Port:       patch,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"population"}},
+	}))
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	assert.False(t, resp.GetAck())
+	assert.Contains(t, resp.GetError(), "population")
+	require.NoError(t, stream.CloseSend())
+}
+
+func TestStreamPortsBidi_ExpectedRevision(t *testing.T) {
+	repo := newTestRepository(t, rotterdam)
+	client := newTestClient(t, repo)
+	ctx := context.Background()
+
+	stream, err := client.StreamPortsBidi(ctx)
+	require.NoError(t, err)
+	renamed := toProto(rotterdam)
+	renamed.Name = "Rotterdam Europoort"
+	revision := uint64(1)
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "1", Port: renamed, ExpectedRevision: &revision}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.True(t, resp.GetAck(), resp.GetError())
+	assert.Equal(t, pb.UpsertOutcome_UPDATED, resp.GetOutcome())
+
+	// The same expected revision is stale now.
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "2", Port: toProto(rotterdam), ExpectedRevision: &revision}))
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	assert.False(t, resp.GetAck())
+	assert.Equal(t, int32(codes.Aborted), resp.GetCode())
+	assert.Contains(t, resp.GetError(), domain.ErrRevisionConflict.Error())
+	require.NoError(t, stream.CloseSend())
+
+	stored, err := repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam Europoort", stored.Name)
+	assert.Equal(t, uint64(2), stored.Revision)
+}
+
+func TestStreamPorts_ExpectedRevisionAborts(t *testing.T) {
+	client := newTestClient(t, newTestRepository(t, rotterdam))
+
+	stream, err := client.StreamPorts(context.Background())
+	require.NoError(t, err)
+	revision := uint64(0)
+	require.NoError(t, stream.Send(&pb.StreamPortsRequest{Uuid: "1", Port: toProto(rotterdam), ExpectedRevision: &revision}))
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.False(t, resp.GetAck())
+	assert.Equal(t, int32(codes.Aborted), resp.GetCode())
+	assert.Equal(t, int32(codes.Aborted), resp.GetSummary().GetErrors()[0].GetCode())
+}
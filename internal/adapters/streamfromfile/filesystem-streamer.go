@@ -1,21 +1,33 @@
 package streamfromfile
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
-	"reflect"
+	"strings"
+	"sync"
 
 	"ports-service/internal/domain"
+	"ports-service/internal/ports"
 )
 
 // FileStreamer is a generic type for streaming data from a JSON file.
 // T is the type of data that will be streamed.
 type FileStreamer[T any] struct {
 	filePath string // Path to the JSON file.
+	// Policy decides what happens to entries that cannot be decoded,
+	// FailFast if empty.
+	Policy ErrorPolicy
+	// DeadLetterPath is the file the DeadLetter policy writes rejected
+	// entries to, one DeadLetterEntry per line. It is truncated by every
+	// stream.
+	DeadLetterPath string
 }
 
 // NewFileStreamer acts as a constructor for FileStreamer.
@@ -24,100 +36,425 @@ func NewFileStreamer[T any](filePath string) *FileStreamer[T] {
 	return &FileStreamer[T]{filePath: filePath}
 }
 
+// ErrorPolicy decides what a FileStreamer does with an entry of the file it
+// cannot decode.
+type ErrorPolicy string
+
+const (
+	// FailFast ends the stream at the first malformed entry.
+	FailFast ErrorPolicy = "fail-fast"
+	// Skip leaves out malformed entries and continues with the next key.
+	Skip ErrorPolicy = "skip"
+	// DeadLetter skips malformed entries like Skip and writes them to the
+	// dead-letter file.
+	DeadLetter ErrorPolicy = "dead-letter"
+)
+
+// ParseErrorPolicy returns the ErrorPolicy named s.
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	switch policy := ErrorPolicy(s); policy {
+	case FailFast, Skip, DeadLetter:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown error policy %q, use %s, %s or %s", s, FailFast, Skip, DeadLetter)
+	}
+}
+
+// DeadLetterEntry is a line of the dead-letter file, an entry of the JSON
+// file that was skipped.
+type DeadLetterEntry struct {
+	Key    string `json:"key,omitempty"` // Empty if the key could not be read.
+	Offset int64  `json:"offset"`        // See StreamError.
+	Error  string `json:"error"`
+	Raw    string `json:"raw"` // The entry as found in the file, key included.
+}
+
+// StreamError reports where streaming a JSON file failed.
+type StreamError struct {
+	Path   string // Path of the JSON file.
+	Offset int64  // Number of bytes of the file read when the error was found.
+	Key    string // Key of the entry being decoded, empty outside an entry.
+	Err    error
+}
+
+func (e *StreamError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%s at byte %d: %v", e.Path, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("%s at byte %d, entry %q: %v", e.Path, e.Offset, e.Key, e.Err)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// FileStream is a single stream of a FileStreamer, see FileStreamer.Stream.
+// Every stream has its own, so streams of the same FileStreamer can run
+// concurrently.
+type FileStream[T any] struct {
+	path    string // Path to the JSON file.
+	objects chan T
+
+	mu      sync.Mutex
+	err     error // Error that ended the stream, see Err.
+	skipped int   // Entries skipped so far, see Skipped.
+}
+
 // StreamObjects streams objects of type T from a JSON file.
 // The use of a buffered channel (make(chan T, bufferSize)) allows some degree of pre-fetching of data,
 // but this buffering is controlled by the bufferSize parameter.
 // The buffer size determines how many objects are held in memory after being read from the file
 // but before being processed by the consumer of the channel.
+// A file that cannot be opened is reported right away; an error reading it
+// closes the channel early, use Stream to learn about it.
 func (fs *FileStreamer[T]) StreamObjects(ctx context.Context, bufferSize int) (<-chan T, error) {
-	ch := make(chan T, bufferSize)
-	go func() {
-		defer close(ch)
+	stream, err := fs.Stream(ctx, bufferSize)
+	if err != nil {
+		return nil, err
+	}
+	return stream.Objects(), nil
+}
 
-		file, err := os.Open(fs.filePath)
-		if err != nil {
-			log.Println("opening file: %w", err)
+// Stream starts streaming objects of type T from the JSON file like
+// StreamObjects and returns the stream, which reports how it ended. An error
+// reading the file closes its channel early and is reported by Err, unless
+// Policy skips the malformed entry and resumes at the next key.
+func (fs *FileStreamer[T]) Stream(ctx context.Context, bufferSize int) (*FileStream[T], error) {
+	policy := fs.Policy
+	if policy == "" {
+		policy = FailFast
+	}
+	if _, err := ParseErrorPolicy(string(policy)); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(fs.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	var deadLetters *os.File
+	if policy == DeadLetter {
+		if deadLetters, err = os.Create(fs.DeadLetterPath); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("creating dead-letter file: %w", err)
 		}
-		defer func(file *os.File) {
-			err := file.Close()
-			if err != nil {
+	}
+
+	stream := &FileStream[T]{path: fs.filePath, objects: make(chan T, bufferSize)}
+	go func() {
+		defer close(stream.objects)
+		defer func() {
+			if err := file.Close(); err != nil {
 				log.Println(err)
 			}
-		}(file)
+		}()
 
-		decoder := json.NewDecoder(file)
+		var reject func(*StreamError, string) error
+		switch policy {
+		case Skip:
+			reject = func(*StreamError, string) error { return nil }
+		case DeadLetter:
+			encoder := json.NewEncoder(deadLetters)
+			reject = func(streamErr *StreamError, raw string) error {
+				return encoder.Encode(DeadLetterEntry{
+					Key: streamErr.Key, Offset: streamErr.Offset, Error: streamErr.Err.Error(), Raw: raw,
+				})
+			}
+		}
 
-		// Check the first token to determine if it's an array or an object.
-		_, err = decoder.Token()
-		if err != nil {
-			log.Printf("Error reading the first JSON token: %v\n", err)
-			return
+		err := stream.stream(ctx, file, reject)
+		if deadLetters != nil {
+			if closeErr := deadLetters.Close(); err == nil && closeErr != nil {
+				err = fmt.Errorf("closing dead-letter file: %w", closeErr)
+			}
 		}
+		stream.setErr(err)
+	}()
+
+	return stream, nil
+}
+
+// Objects returns the channel the objects of the stream are sent on. It is
+// closed when the stream ends.
+func (s *FileStream[T]) Objects() <-chan T {
+	return s.objects
+}
+
+// Err returns the error that ended the stream before the end of the file
+// once its channel has been closed: a *StreamError, or the error of ctx if
+// it was done. It is nil if the whole file was streamed.
+func (s *FileStream[T]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *FileStream[T]) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Skipped returns how many malformed entries the stream skipped so far,
+// always 0 for the FailFast policy.
+func (s *FileStream[T]) Skipped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.skipped
+}
 
-		// Iterate over each entry in the JSON object
-		for decoder.More() {
-			// Read the key
-			key, err := decoder.Token()
+// stream sends every entry of the JSON object in file to the objects
+// channel. A malformed entry ends the stream if reject is nil; otherwise it
+// is passed to reject along with its raw bytes and the stream resumes at the
+// next key.
+func (s *FileStream[T]) stream(ctx context.Context, file *os.File, reject func(*StreamError, string) error) error {
+	decoder := json.NewDecoder(file)
+	var base int64 // Offset in file of the first byte the decoder reads.
+	offset := func() int64 {
+		return base + decoder.InputOffset()
+	}
+	fail := func(key string, err error) *StreamError {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		at := offset()
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			at = base + syntaxErr.Offset
+		}
+		return &StreamError{Path: s.path, Offset: at, Key: key, Err: err}
+	}
+
+	// The entries are the members of a single top-level object.
+	token, err := decoder.Token()
+	if err != nil {
+		return fail("", err)
+	}
+	if token != json.Delim('{') {
+		return fail("", fmt.Errorf("expected a JSON object, found %v", token))
+	}
+
+	// Iterate over each entry in the JSON object
+	for decoder.More() {
+		start := offset()
+
+		// Read the key, the decoder only yields strings for object keys.
+		var key string
+		var raw json.RawMessage
+		token, err := decoder.Token()
+		if err == nil {
+			key = token.(string)
+			err = decoder.Decode(&raw)
+		}
+		if err != nil {
+			// Only a syntax error can be skipped. It leaves the decoder
+			// stuck at it, so the decoder is restarted after the entry.
+			streamErr := fail(key, err)
+			var syntaxErr *json.SyntaxError
+			if reject == nil || !errors.As(err, &syntaxErr) {
+				return streamErr
+			}
+			end, sep, err := entryEnd(file, start)
 			if err != nil {
-				fmt.Println(err)
-				return
+				return streamErr
 			}
-
-			var item T
-			if err := decoder.Decode(&item); err != nil {
-				if err != io.EOF {
-					log.Printf("Error decoding object: %v\n", err)
-				}
-				break
+			decoder, base = resumeAt(file, end, sep)
+			if err := s.skip(streamErr, file, start, end, reject); err != nil {
+				return err
 			}
+			continue
+		}
 
-			SetKey(&item, key.(string))
+		var item T
+		if err := json.Unmarshal(raw, &item); err != nil {
+			// The decoder is past the entry already.
+			if err := s.skip(fail(key, err), file, start, offset(), reject); err != nil {
+				return err
+			}
+			continue
+		}
 
-			//TODO: Remove this debug code
-			//// Print the key and location
-			//fmt.Printf("Key: %v, Location: %+v\n", key, item)
+		ports.SetKey(&item, key)
 
-			select {
-			case <-ctx.Done():
-				return
-			case ch <- item:
-			}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case s.objects <- item:
 		}
-	}()
+	}
+
+	// A truncated file ends without the closing brace.
+	if _, err := decoder.Token(); err != nil {
+		return fail("", err)
+	}
+	return nil
+}
+
+// skip passes the malformed entry of file from start to end to reject, or
+// returns streamErr if reject is nil.
+func (s *FileStream[T]) skip(streamErr *StreamError, file io.ReaderAt, start, end int64, reject func(*StreamError, string) error) error {
+	if reject == nil {
+		return streamErr
+	}
 
-	return ch, nil
+	log.Printf("Skipping malformed entry: %v", streamErr)
+	if err := reject(streamErr, rawEntry(file, start, end)); err != nil {
+		return fmt.Errorf("rejecting entry: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipped++
+	return nil
 }
 
-// SetKey sets the field "Key" to the given value on the passed
-// struct pointer item, if the field exists. The struct type
-// passed for T must be a pointer. Helpful to preserve metadata e.g. Key
-// that would be lost otherwise.
-func SetKey[T any](item *T, value string) {
-	t := reflect.TypeOf(item).Elem()
-	v := reflect.ValueOf(item).Elem()
+// entryEnd returns the offset in file of the ',' or '}' ending the entry of
+// the top-level object at offset start, the first one outside of strings,
+// objects and arrays, along with that separator. A separator left of the
+// entry is skipped, as are brackets closing none that is open. It fails
+// with io.ErrUnexpectedEOF if there is none.
+func entryEnd(file io.ReaderAt, start int64) (int64, byte, error) {
+	r := bufio.NewReader(io.NewSectionReader(file, start, math.MaxInt64-start))
+	var open []byte // Closing brackets of the open objects and arrays.
+	var began, inString, escaped bool
+	for end := start; ; end++ {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			return 0, 0, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return 0, 0, err
+		}
+
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case !began && c == ',':
+			began = true
+		case c == '"':
+			began, inString = true, true
+		case c == '{':
+			began = true
+			open = append(open, '}')
+		case c == '[':
+			began = true
+			open = append(open, ']')
+		case len(open) > 0 && c == open[len(open)-1]:
+			open = open[:len(open)-1]
+		case len(open) == 0 && (c == ',' || c == '}'):
+			return end, c, nil
+		default:
+			began = true
+		}
+	}
+}
 
-	if field, ok := t.FieldByName("Key"); ok {
-		v.FieldByIndex(field.Index).SetString(value)
+// resumeAt returns a decoder positioned before the next entry of the
+// top-level object, following the separator sep at offset end of file, and
+// the offset in file its input starts at.
+func resumeAt(file io.ReaderAt, end int64, sep byte) (*json.Decoder, int64) {
+	// Reopening the object lets the decoder check the entries that follow.
+	next := end + 1
+	if sep == '}' {
+		next = end
 	}
+	rest := io.NewSectionReader(file, next, math.MaxInt64-next)
+	decoder := json.NewDecoder(io.MultiReader(strings.NewReader("{"), rest))
+	decoder.Token() // Reads the '{' above.
+	return decoder, next - 1
+}
+
+// rawEntry returns the bytes of file from start to end, without the
+// surrounding whitespace and separator.
+func rawEntry(file io.ReaderAt, start, end int64) string {
+	raw := make([]byte, end-start)
+	n, _ := file.ReadAt(raw, start)
+	return strings.TrimLeft(strings.TrimSpace(string(raw[:n])), ", \t\n\r")
 }
 
 type PortService struct {
 	PortForShipsRepository domain.StorePortRepository
+	// Merge treats every entry of the file as a JSON merge patch of the
+	// stored Port, updating only the fields it carries.
+	Merge bool
+	// ErrorPolicy and DeadLetterPath configure the FileStreamer reading the
+	// file, see FileStreamer.Policy.
+	ErrorPolicy    ErrorPolicy
+	DeadLetterPath string
 }
 
 // StreamJSONfromFile streams objects of type T from a JSON file. TODO: Perhaps move this to a service/application layer?
+// Invalid ports are skipped and reported in the returned error, which then
+// matches domain.ErrInvalidPort; any other store failure stops the stream.
+// A file that cannot be read to its end yields a *StreamError telling where
+// it broke off, after the entries before that point have been stored.
+// Malformed entries end the stream the same way unless ErrorPolicy skips
+// them; their number is logged with the other counts.
 func (p PortService) StreamJSONfromFile(ctx context.Context, filePath string, bufferSize int) error {
-	streamer := NewFileStreamer[domain.Port](filePath)
-	portStream, err := streamer.StreamObjects(ctx, bufferSize)
+	repo := p.PortForShipsRepository
+	if p.Merge {
+		return ingestFile(ctx, newFileStreamer[domain.PortPatch](p, filePath), bufferSize, func(ctx context.Context, patch domain.PortPatch) (ports.UpsertOutcome, error) {
+			patch.Port.Key = patch.Key
+			return repo.Merge(ctx, patch.Port, patch.Fields)
+		})
+	}
+	return ingestFile(ctx, newFileStreamer[domain.Port](p, filePath), bufferSize, repo.Upsert)
+}
+
+// newFileStreamer returns a FileStreamer of filePath configured by p.
+func newFileStreamer[T any](p PortService, filePath string) *FileStreamer[T] {
+	streamer := NewFileStreamer[T](filePath)
+	streamer.Policy = p.ErrorPolicy
+	streamer.DeadLetterPath = p.DeadLetterPath
+	return streamer
+}
+
+// ingestFile stores every entry streamed from the JSON file with store. An
+// error reading the file is returned once the entries before it are stored.
+func ingestFile[T any](ctx context.Context, streamer *FileStreamer[T], bufferSize int, store func(context.Context, T) (ports.UpsertOutcome, error)) error {
+	// Stops the streamer when a store failure ends the ingestion early.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	filePath := streamer.filePath
+	stream, err := streamer.Stream(ctx, bufferSize)
 	if err != nil {
 		return fmt.Errorf("setting up JSON stream from filesystem: %w", err)
 	}
 
-	for port := range portStream {
-		err := p.PortForShipsRepository.Store(ctx, port)
+	// Ports violating the domain rules are skipped so one bad entry does not
+	// stop the rest of the file from being ingested. They are reported
+	// together once the file has been read.
+	var invalid []error
+	outcomes := make(map[ports.UpsertOutcome]int)
+	for item := range stream.Objects() {
+		outcome, err := store(ctx, item)
+		if errors.Is(err, domain.ErrInvalidPort) {
+			log.Printf("Skipping invalid port: %v", err)
+			invalid = append(invalid, err)
+			continue
+		}
 		if err != nil {
 			return fmt.Errorf("set fails on Data from StorePortRepository: %w", err)
 		}
+		outcomes[outcome]++
+	}
+	log.Printf("Ingested %s: created %d, updated %d, unchanged %d, invalid %d, malformed %d", filePath,
+		outcomes[ports.Created], outcomes[ports.Updated], outcomes[ports.Unchanged], len(invalid), stream.Skipped())
+	if streamer.Policy == DeadLetter && stream.Skipped() > 0 {
+		log.Printf("Wrote %d malformed entries to %s", stream.Skipped(), streamer.DeadLetterPath)
+	}
+	if err := stream.Err(); err != nil {
+		return fmt.Errorf("reading JSON stream from filesystem: %w", err)
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("%d invalid ports in %s: %w", len(invalid), filePath, errors.Join(invalid...))
 	}
 	return nil
 }
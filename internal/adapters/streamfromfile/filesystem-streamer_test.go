@@ -2,12 +2,23 @@ package streamfromfile_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"ports-service/internal/adapters/database"
 	"ports-service/internal/adapters/streamfromfile"
+	"ports-service/internal/domain"
+	"ports-service/internal/ports"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type TestObject struct {
@@ -56,10 +67,339 @@ func TestStreamObjects_InvalidJSON(t *testing.T) {
 
 	fileStreamer := streamfromfile.NewFileStreamer[TestObject](filePath)
 	ctx := context.Background()
-	ch, err := fileStreamer.StreamObjects(ctx, 2)
+	stream, err := fileStreamer.Stream(ctx, 2)
 	assert.NoError(t, err)
 
 	// Expect no objects and channel to be closed
-	_, ok := <-ch
+	_, ok := <-stream.Objects()
 	assert.False(t, ok, "channel should be closed with no objects sent")
+
+	var streamErr *streamfromfile.StreamError
+	assert.ErrorAs(t, stream.Err(), &streamErr)
+}
+
+func TestStreamObjects_MissingFile(t *testing.T) {
+	fileStreamer := streamfromfile.NewFileStreamer[TestObject]("does-not-exist.json")
+	_, err := fileStreamer.StreamObjects(context.Background(), 2)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestStreamObjects_EmptyFile(t *testing.T) {
+	filePath, err := createTempJSONFile(``)
+	require.NoError(t, err)
+	defer os.Remove(filePath)
+
+	fileStreamer := streamfromfile.NewFileStreamer[TestObject](filePath)
+	stream, err := fileStreamer.Stream(context.Background(), 2)
+	require.NoError(t, err)
+	for range stream.Objects() {
+	}
+	assert.ErrorIs(t, stream.Err(), io.ErrUnexpectedEOF)
+}
+
+func TestStreamObjects_ErrorPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		key     string
+		offset  int64
+	}{
+		{
+			name:    "bad value",
+			content: `{"obj1": {"value": "one"}, "obj2": {"value": x}}`,
+			key:     "obj2",
+			offset:  46,
+		},
+		{
+			name:    "wrong type",
+			content: `{"obj1": {"value": "one"}, "obj2": {"value": 2}, "obj3": {}}`,
+			key:     "obj2",
+			offset:  47,
+		},
+		{
+			name:    "truncated",
+			content: `{"obj1": {"value": "one"}`,
+			offset:  25,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath, err := createTempJSONFile(tt.content)
+			require.NoError(t, err)
+			defer os.Remove(filePath)
+
+			fileStreamer := streamfromfile.NewFileStreamer[TestObject](filePath)
+			stream, err := fileStreamer.Stream(context.Background(), 2)
+			require.NoError(t, err)
+
+			// The entry before the error is streamed.
+			var got []TestObject
+			for obj := range stream.Objects() {
+				got = append(got, obj)
+			}
+			assert.Equal(t, []TestObject{{Key: "obj1", Value: "one"}}, got)
+
+			var streamErr *streamfromfile.StreamError
+			require.ErrorAs(t, stream.Err(), &streamErr)
+			assert.Equal(t, filePath, streamErr.Path)
+			assert.Equal(t, tt.key, streamErr.Key)
+			assert.Equal(t, tt.offset, streamErr.Offset)
+		})
+	}
+}
+
+func TestStreamObjects_ErrorPolicy(t *testing.T) {
+	// A syntax error in a value, a value of the wrong type, a key without
+	// quotes and a syntax error in the last entry.
+	content := `{
+		"obj1": {"value": "one"},
+		"obj2": {"value": x, "nested": {"a": [1, "}"]}},
+		"obj3": {"value": 3},
+		obj4: {"value": "four"},
+		"obj5": {"value": "five"},
+		"obj6": {"value": ]}
+	}`
+	filePath, err := createTempJSONFile(content)
+	require.NoError(t, err)
+	defer os.Remove(filePath)
+	deadLetterPath := filepath.Join(t.TempDir(), "dead-letter.ndjson")
+
+	for _, policy := range []streamfromfile.ErrorPolicy{streamfromfile.Skip, streamfromfile.DeadLetter} {
+		t.Run(string(policy), func(t *testing.T) {
+			fileStreamer := streamfromfile.NewFileStreamer[TestObject](filePath)
+			fileStreamer.Policy = policy
+			fileStreamer.DeadLetterPath = deadLetterPath
+			stream, err := fileStreamer.Stream(context.Background(), 2)
+			require.NoError(t, err)
+
+			var got []TestObject
+			for obj := range stream.Objects() {
+				got = append(got, obj)
+			}
+			require.NoError(t, stream.Err())
+			assert.Equal(t, []TestObject{{Key: "obj1", Value: "one"}, {Key: "obj5", Value: "five"}}, got)
+			assert.Equal(t, 4, stream.Skipped())
+		})
+	}
+
+	data, err := os.ReadFile(deadLetterPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 4)
+	var entries []streamfromfile.DeadLetterEntry
+	for _, line := range lines {
+		var entry streamfromfile.DeadLetterEntry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	assert.Equal(t, `"obj2": {"value": x, "nested": {"a": [1, "}"]}}`, entries[0].Raw)
+	assert.Equal(t, "obj2", entries[0].Key)
+	assert.Equal(t, int64(strings.Index(content, "x")+1), entries[0].Offset)
+	assert.Equal(t, `"obj3": {"value": 3}`, entries[1].Raw)
+	assert.Contains(t, entries[1].Error, "cannot unmarshal number")
+	assert.Equal(t, `obj4: {"value": "four"}`, entries[2].Raw)
+	assert.Empty(t, entries[2].Key)
+	assert.Equal(t, `"obj6": {"value": ]}`, entries[3].Raw)
+}
+
+func TestStreamObjects_ErrorPolicyTruncated(t *testing.T) {
+	filePath, err := createTempJSONFile(`{"obj1": {"value": "one"}, "obj2": {"value": x`)
+	require.NoError(t, err)
+	defer os.Remove(filePath)
+
+	// A malformed entry that never ends cannot be skipped.
+	fileStreamer := streamfromfile.NewFileStreamer[TestObject](filePath)
+	fileStreamer.Policy = streamfromfile.Skip
+	stream, err := fileStreamer.Stream(context.Background(), 2)
+	require.NoError(t, err)
+	for range stream.Objects() {
+	}
+	var streamErr *streamfromfile.StreamError
+	require.ErrorAs(t, stream.Err(), &streamErr)
+	assert.Equal(t, "obj2", streamErr.Key)
+	assert.Zero(t, stream.Skipped())
+}
+
+func TestStream_Concurrent(t *testing.T) {
+	var content strings.Builder
+	content.WriteString(`{"bad": {"value": x}`)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&content, `, "obj%d": {"value": "%d"}`, i, i)
+	}
+	content.WriteString(`}`)
+	filePath, err := createTempJSONFile(content.String())
+	require.NoError(t, err)
+	defer os.Remove(filePath)
+
+	// Both streams share the FileStreamer but report their own outcome.
+	fileStreamer := streamfromfile.NewFileStreamer[TestObject](filePath)
+	fileStreamer.Policy = streamfromfile.Skip
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		stream, err := fileStreamer.Stream(context.Background(), 2)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var n int
+		for range stream.Objects() {
+			n++
+		}
+		assert.Equal(t, 100, n)
+		assert.NoError(t, stream.Err())
+		assert.Equal(t, 1, stream.Skipped())
+	}()
+	go func() {
+		defer wg.Done()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stream, err := fileStreamer.Stream(ctx, 0)
+		if !assert.NoError(t, err) {
+			return
+		}
+		<-stream.Objects()
+		cancel()
+		for range stream.Objects() {
+		}
+		assert.ErrorIs(t, stream.Err(), context.Canceled)
+		assert.Equal(t, 1, stream.Skipped())
+	}()
+	wg.Wait()
+}
+
+func TestParseErrorPolicy(t *testing.T) {
+	policy, err := streamfromfile.ParseErrorPolicy("dead-letter")
+	require.NoError(t, err)
+	assert.Equal(t, streamfromfile.DeadLetter, policy)
+
+	_, err = streamfromfile.ParseErrorPolicy("retry")
+	assert.Error(t, err)
+}
+
+func TestStreamJSONfromFile_SkipsInvalidPorts(t *testing.T) {
+	filePath, err := createTempJSONFile(`{
+		"AEAJM": {"name": "Ajman", "coordinates": [55.5136433, 25.4052165], "timezone": "Asia/Dubai", "unlocs": ["AEAJM"]},
+		"ARRIC": {"name": "Rio Cullen", "timezone": "America/Argentina", "unlocs": ["ARRIC"]},
+		"AEAUH": {"name": "Abu Dhabi", "coordinates": [54.37, 24.47], "timezone": "Asia/Dubai", "unlocs": ["AEAUH"]}
+	}`)
+	assert.NoError(t, err)
+	defer os.Remove(filePath)
+
+	db := database.NewMemDB[domain.Port]()
+	portService := streamfromfile.PortService{PortForShipsRepository: domain.StorePortRepository{Data: db}}
+
+	err = portService.StreamJSONfromFile(context.Background(), filePath, 2)
+	assert.ErrorIs(t, err, domain.ErrInvalidPort)
+	assert.Contains(t, err.Error(), "ARRIC")
+
+	assert.Equal(t, 2, db.Len())
+	_, err = db.Get(context.Background(), "ARRIC")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+}
+
+func TestStreamJSONfromFile_SkipsMalformedCoordinates(t *testing.T) {
+	filePath, err := createTempJSONFile(`{
+		"AEFJR": {"name": "Al Fujayrah", "coordinates": [56.33, 25.12, 0], "timezone": "Asia/Dubai", "unlocs": ["AEFJR"]},
+		"AEAUH": {"name": "Abu Dhabi", "coordinates": [54.37, 24.47], "timezone": "Asia/Dubai", "unlocs": ["AEAUH"]}
+	}`)
+	require.NoError(t, err)
+	defer os.Remove(filePath)
+
+	db := database.NewMemDB[domain.Port]()
+	portService := streamfromfile.PortService{PortForShipsRepository: domain.StorePortRepository{Data: db}}
+
+	// The default fail-fast policy only applies to malformed JSON, so the
+	// Port with three coordinates is rejected on its own.
+	err = portService.StreamJSONfromFile(context.Background(), filePath, 2)
+	assert.ErrorIs(t, err, domain.ErrInvalidPort)
+	assert.Contains(t, err.Error(), "AEFJR")
+	var streamErr *streamfromfile.StreamError
+	assert.False(t, errors.As(err, &streamErr))
+
+	port, err := db.Get(context.Background(), "AEAUH")
+	require.NoError(t, err)
+	assert.Equal(t, "Abu Dhabi", port.Name)
+	_, err = db.Get(context.Background(), "AEFJR")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+}
+
+func TestStreamJSONfromFile_StreamError(t *testing.T) {
+	filePath, err := createTempJSONFile(`{
+		"AEAJM": {"name": "Ajman", "coordinates": [55.5136433, 25.4052165], "timezone": "Asia/Dubai", "unlocs": ["AEAJM"]},
+		"ARRIC": {"name": "Rio Cullen", "timezone": "America/Argentina", "unlocs": ["ARRIC"]},
+		"AEAUH": {"name": "Abu Dhabi", "coordinates": [54.37, 24.47], "unlocs": "AEAUH"}`)
+	require.NoError(t, err)
+	defer os.Remove(filePath)
+
+	db := database.NewMemDB[domain.Port]()
+	portService := streamfromfile.PortService{PortForShipsRepository: domain.StorePortRepository{Data: db}}
+
+	// The stream error wins over the invalid Port before it.
+	err = portService.StreamJSONfromFile(context.Background(), filePath, 2)
+	var streamErr *streamfromfile.StreamError
+	require.ErrorAs(t, err, &streamErr)
+	assert.Equal(t, "AEAUH", streamErr.Key)
+	assert.NotErrorIs(t, err, domain.ErrInvalidPort)
+
+	assert.Equal(t, 1, db.Len())
+}
+
+func TestStreamJSONfromFile_DeadLetter(t *testing.T) {
+	filePath, err := createTempJSONFile(`{
+		"AEAJM": {"name": "Ajman", "coordinates": [55.5136433, 25.4052165], "timezone": "Asia/Dubai", "unlocs": ["AEAJM"]},
+		"ARRIC": {"name": "Rio Cullen", "coordinates": [-68.36, -52.9], "unlocs": "ARRIC"},
+		"AEAUH": {"name": "Abu Dhabi", "coordinates": [54.37, 24.47], "timezone": "Asia/Dubai", "unlocs": ["AEAUH"]}
+	}`)
+	require.NoError(t, err)
+	defer os.Remove(filePath)
+	deadLetterPath := filepath.Join(t.TempDir(), "dead-letter.ndjson")
+
+	db := database.NewMemDB[domain.Port]()
+	portService := streamfromfile.PortService{
+		PortForShipsRepository: domain.StorePortRepository{Data: db},
+		ErrorPolicy:            streamfromfile.DeadLetter,
+		DeadLetterPath:         deadLetterPath,
+	}
+	require.NoError(t, portService.StreamJSONfromFile(context.Background(), filePath, 2))
+	assert.Equal(t, 2, db.Len())
+
+	data, err := os.ReadFile(deadLetterPath)
+	require.NoError(t, err)
+	var entry streamfromfile.DeadLetterEntry
+	require.NoError(t, json.Unmarshal(data, &entry))
+	assert.Equal(t, "ARRIC", entry.Key)
+}
+
+func TestStreamJSONfromFile_Merge(t *testing.T) {
+	filePath, err := createTempJSONFile(`{
+		"AEAJM": {"coordinates": [55.5, 25.4]},
+		"AEAUH": {"timezone": null, "alias": ["Abu Zaby"]}
+	}`)
+	assert.NoError(t, err)
+	defer os.Remove(filePath)
+
+	ctx := context.Background()
+	repo := domain.StorePortRepository{Data: database.NewMemDB[domain.Port]()}
+	ajman := domain.Port{Key: "AEAJM", Name: "Ajman", Timezone: "Asia/Dubai", Unlocs: []string{"AEAJM"}}
+	abuDhabi := domain.Port{Key: "AEAUH", Name: "Abu Dhabi", Timezone: "Asia/Dubai", Unlocs: []string{"AEAUH"}}
+	assert.NoError(t, repo.Store(ctx, ajman))
+	assert.NoError(t, repo.Store(ctx, abuDhabi))
+
+	portService := streamfromfile.PortService{PortForShipsRepository: repo, Merge: true}
+	assert.NoError(t, portService.StreamJSONfromFile(ctx, filePath, 2))
+
+	// Only the fields present in the file change, null clears a field.
+	ajman.Coordinates = &domain.GeoPoint{Lat: 25.4, Lon: 55.5}
+	got, err := repo.Get(ctx, "AEAJM")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), got.Revision)
+	assert.True(t, ajman.Equal(got), "%+v", got)
+	abuDhabi.Timezone = ""
+	abuDhabi.Alias = []string{"Abu Zaby"}
+	got, err = repo.Get(ctx, "AEAUH")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), got.Revision)
+	assert.True(t, abuDhabi.Equal(got), "%+v", got)
 }
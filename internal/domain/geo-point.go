@@ -0,0 +1,123 @@
+package domain
+
+// Package domain contains the core business entities and logic.
+// In Domain-Driven Design (DDD), this package is the heart of the business logic,
+// encapsulating the domain model and rules.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// EarthRadiusKm is the mean radius of the Earth used for distances.
+const EarthRadiusKm = 6371.0088
+
+// GeoPoint is a value object for a position on the globe in decimal degrees.
+// The ports data files store positions as a [longitude, latitude] array;
+// naming the axes keeps code working with positions from mixing them up.
+type GeoPoint struct {
+	Lat float64 // Latitude, from -90 (south) to 90 (north).
+	Lon float64 // Longitude, from -180 (west) to 180 (east).
+
+	// malformed is why UnmarshalJSON could not read a position from the
+	// input, reported by Validate so a bad entry rejects only its Port.
+	malformed string
+}
+
+// GeoPointFromCoordinates converts the [longitude, latitude] array form used
+// by the ports data files. An empty array yields nil, as not every port has
+// a known position.
+func GeoPointFromCoordinates(coordinates []float64) (*GeoPoint, error) {
+	switch len(coordinates) {
+	case 0:
+		return nil, nil
+	case 2:
+		return &GeoPoint{Lat: coordinates[1], Lon: coordinates[0]}, nil
+	default:
+		return nil, fmt.Errorf("must be [longitude, latitude], got %d values", len(coordinates))
+	}
+}
+
+// Coordinates returns g in the [longitude, latitude] array form.
+func (g GeoPoint) Coordinates() []float64 {
+	return []float64{g.Lon, g.Lat}
+}
+
+// DistanceKm returns the great-circle distance between g and other in
+// kilometres, using the haversine formula.
+func (g GeoPoint) DistanceKm(other GeoPoint) float64 {
+	lat1, lat2 := toRadians(g.Lat), toRadians(other.Lat)
+	dLat := lat2 - lat1
+	dLon := toRadians(other.Lon - g.Lon)
+
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// ErrInvalidGeoPoint is matched (via errors.Is) by every error Validate returns.
+var ErrInvalidGeoPoint = errors.New("invalid geo point")
+
+// Validate returns an error matching ErrInvalidGeoPoint if g lies outside
+// the valid coordinate range.
+func (g GeoPoint) Validate() error {
+	if reasons := g.validate(); len(reasons) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidGeoPoint, strings.Join(reasons, "; "))
+	}
+	return nil
+}
+
+// validate returns the reasons g lies outside the valid coordinate range.
+func (g GeoPoint) validate() []string {
+	if g.malformed != "" {
+		return []string{g.malformed}
+	}
+	var reasons []string
+	if g.Lon < -180 || g.Lon > 180 {
+		reasons = append(reasons, fmt.Sprintf("longitude %v out of range [-180, 180]", g.Lon))
+	}
+	if g.Lat < -90 || g.Lat > 90 {
+		reasons = append(reasons, fmt.Sprintf("latitude %v out of range [-90, 90]", g.Lat))
+	}
+	return reasons
+}
+
+// MarshalJSON encodes g in the [longitude, latitude] array form.
+func (g GeoPoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(g.Coordinates())
+}
+
+// UnmarshalJSON decodes the [longitude, latitude] array form, as well as an
+// object with explicit "lat" and "lon" members. An array of the wrong length
+// or an object missing a member decodes to a point that fails Validate.
+func (g *GeoPoint) UnmarshalJSON(data []byte) error {
+	var coordinates []float64
+	if err := json.Unmarshal(data, &coordinates); err == nil {
+		if len(coordinates) != 2 {
+			*g = GeoPoint{malformed: fmt.Sprintf("must be [longitude, latitude], got %d values", len(coordinates))}
+			return nil
+		}
+		*g = GeoPoint{Lat: coordinates[1], Lon: coordinates[0]}
+		return nil
+	}
+
+	var object struct {
+		Lat *float64 `json:"lat"`
+		Lon *float64 `json:"lon"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("coordinates must be [longitude, latitude] or {\"lat\": ..., \"lon\": ...}: %w", err)
+	}
+	if object.Lat == nil || object.Lon == nil {
+		*g = GeoPoint{malformed: "must have both lat and lon"}
+		return nil
+	}
+	*g = GeoPoint{Lat: *object.Lat, Lon: *object.Lon}
+	return nil
+}
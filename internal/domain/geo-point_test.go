@@ -0,0 +1,103 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"ports-service/internal/domain"
+)
+
+func TestGeoPoint_UnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name        string
+		json        string
+		want        *domain.GeoPoint
+		wantInvalid bool
+		wantErr     bool
+	}{
+		{
+			name: "LonLatArray",
+			json: `{"coordinates": [55.5136433, 25.4052165]}`,
+			want: &domain.GeoPoint{Lat: 25.4052165, Lon: 55.5136433},
+		},
+		{
+			name: "Object",
+			json: `{"coordinates": {"lat": 25.4052165, "lon": 55.5136433}}`,
+			want: &domain.GeoPoint{Lat: 25.4052165, Lon: 55.5136433},
+		},
+		{
+			name: "Missing",
+			json: `{}`,
+		},
+		{
+			name: "Null",
+			json: `{"coordinates": null}`,
+		},
+		{
+			name:        "SingleValue",
+			json:        `{"coordinates": [55.5136433]}`,
+			wantInvalid: true,
+		},
+		{
+			name:        "ThreeValues",
+			json:        `{"coordinates": [55.5136433, 25.4052165, 0]}`,
+			wantInvalid: true,
+		},
+		{
+			name:        "ObjectWithoutLon",
+			json:        `{"coordinates": {"lat": 25.4052165}}`,
+			wantInvalid: true,
+		},
+		{
+			name:    "String",
+			json:    `{"coordinates": "55.5136433, 25.4052165"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var port domain.Port
+			err := json.Unmarshal([]byte(tc.json), &port)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tc.wantInvalid {
+				err := port.Validate()
+				assert.ErrorIs(t, err, domain.ErrInvalidPort)
+				assert.ErrorContains(t, err, "coordinates")
+				return
+			}
+			assert.Equal(t, tc.want, port.Coordinates)
+		})
+	}
+}
+
+func TestGeoPoint_MarshalJSON(t *testing.T) {
+	port := domain.Port{Key: "AEAJM", Coordinates: &domain.GeoPoint{Lat: 25.4052165, Lon: 55.5136433}}
+
+	data, err := json.Marshal(port)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"coordinates":[55.5136433,25.4052165]`)
+
+	var decoded domain.Port
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, port, decoded)
+}
+
+func TestGeoPointFromCoordinates(t *testing.T) {
+	point, err := domain.GeoPointFromCoordinates([]float64{4.47917, 51.9225})
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}, point)
+	assert.Equal(t, []float64{4.47917, 51.9225}, point.Coordinates())
+
+	point, err = domain.GeoPointFromCoordinates(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, point)
+
+	_, err = domain.GeoPointFromCoordinates([]float64{1, 2, 3})
+	assert.Error(t, err)
+}
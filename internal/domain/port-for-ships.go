@@ -4,10 +4,21 @@ package domain
 // In Domain-Driven Design (DDD), this package is the heart of the business logic,
 // encapsulating the domain model and rules.
 
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
 // Port is the aggregate root and an entity in the domain model of a logistics or
 // maritime system. In DDD, an aggregate root is a main entity within an aggregate,
 // a cluster of domain objects that can be treated as a single unit for data changes.
-// The Port aggregate would include all the domain logic and rules applicable to a port.
+// The Port aggregate includes all the domain logic and rules applicable to a port,
+// see Validate.
 
 type Port struct {
 	Key         string    `json:"key"`         // Unique identifier for the Port.
@@ -16,9 +27,136 @@ type Port struct {
 	Country     string    `json:"country"`     // Country where the Port is located.
 	Alias       []string  `json:"alias"`       // Alternative names or identifiers for the Port.
 	Regions     []string  `json:"regions"`     // Geographical or administrative regions associated with the Port.
-	Coordinates []float64 `json:"coordinates"` // Geographical coordinates of the Port, typically latitude and longitude.
+	Coordinates *GeoPoint `json:"coordinates"` // Geographical position of the Port, nil if unknown.
 	Province    string    `json:"province"`    // Province or state where the Port is located.
 	Timezone    string    `json:"timezone"`    // Time zone of the Port.
 	Unlocs      []string  `json:"unlocs"`      // United Nations Location Codes for the Port.
 	Code        string    `json:"code"`        // Additional coding system
+
+	// Revision counts the changes stored through a PortRepository, starting
+	// at 1; it is 0 for a Port that never was.
+	Revision  uint64     `json:"revision,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"` // When Revision was stored, nil if Revision is 0.
+	// DeletedAt is when the Port was deleted, nil unless it is a tombstone
+	// kept to be restored, see StorePortRepository.Delete.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Deleted reports whether p is the tombstone of a deleted Port.
+func (p Port) Deleted() bool {
+	return p.DeletedAt != nil
+}
+
+// ErrInvalidPort is matched (via errors.Is) by every error Validate returns.
+var ErrInvalidPort = errors.New("invalid port")
+
+// unlocodePattern matches a UN/LOCODE: an ISO 3166-1 alpha-2 country code
+// followed by three letters or digits 2-9.
+var unlocodePattern = regexp.MustCompile(`^[A-Z]{2}[A-Z2-9]{3}$`)
+
+// FieldError describes a single rule a Port violates.
+type FieldError struct {
+	Field  string // JSON name of the offending field.
+	Reason string
+}
+
+func (e FieldError) String() string {
+	return e.Field + ": " + e.Reason
+}
+
+// ValidationError lists every rule a Port violates.
+type ValidationError struct {
+	Key    string
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		reasons = append(reasons, field.String())
+	}
+	return fmt.Sprintf("%v %q: %s", ErrInvalidPort, e.Key, strings.Join(reasons, "; "))
+}
+
+// Is makes errors.Is(err, ErrInvalidPort) true for a *ValidationError.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidPort
+}
+
+// Validate checks the invariants of the Port aggregate and returns a
+// *ValidationError listing all violations, or nil for a valid Port.
+// Coordinates and Timezone are optional, as not every port has them on
+// record, but must be well-formed when present.
+func (p Port) Validate() error {
+	var fields []FieldError
+	violate := func(field, reason string) {
+		fields = append(fields, FieldError{Field: field, Reason: reason})
+	}
+
+	if !unlocodePattern.MatchString(p.Key) {
+		violate("key", "must be a UN/LOCODE like NLRTM")
+	}
+	if strings.TrimSpace(p.Name) == "" {
+		violate("name", "must not be empty")
+	}
+
+	if p.Coordinates != nil {
+		for _, reason := range p.Coordinates.validate() {
+			violate("coordinates", reason)
+		}
+	}
+
+	if p.Timezone != "" {
+		if err := checkTimezone(p.Timezone); err != nil {
+			violate("timezone", fmt.Sprintf("unknown IANA time zone %q", p.Timezone))
+		}
+	}
+
+	if !slices.Contains(p.Unlocs, p.Key) {
+		violate("unlocs", fmt.Sprintf("must contain the key %q", p.Key))
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Key: p.Key, Fields: fields}
+}
+
+// timezones caches the outcome of loading a time zone, as time.LoadLocation
+// reads the zone database on every call.
+var timezones sync.Map // map[string]error
+
+func checkTimezone(name string) error {
+	if err, ok := timezones.Load(name); ok {
+		if err == nil {
+			return nil
+		}
+		return err.(error)
+	}
+
+	_, err := time.LoadLocation(name)
+	timezones.Store(name, err)
+	return err
+}
+
+// Equal reports whether p and other describe the same Port, regardless of
+// their Revision and UpdatedAt. Unlike reflect.DeepEqual it treats nil and
+// empty lists alike, as stores do not tell them apart. A tombstone never
+// equals a live Port.
+func (p Port) Equal(other Port) bool {
+	if p.Key != other.Key || p.Name != other.Name || p.City != other.City ||
+		p.Country != other.Country || p.Province != other.Province ||
+		p.Timezone != other.Timezone || p.Code != other.Code {
+		return false
+	}
+	if p.Deleted() != other.Deleted() || (p.Deleted() && !p.DeletedAt.Equal(*other.DeletedAt)) {
+		return false
+	}
+	if (p.Coordinates == nil) != (other.Coordinates == nil) ||
+		(p.Coordinates != nil && *p.Coordinates != *other.Coordinates) {
+		return false
+	}
+	return slices.Equal(p.Alias, other.Alias) &&
+		slices.Equal(p.Regions, other.Regions) &&
+		slices.Equal(p.Unlocs, other.Unlocs)
 }
@@ -0,0 +1,116 @@
+package domain
+
+// Package domain contains the core business entities and logic.
+// In Domain-Driven Design (DDD), this package is the heart of the business logic,
+// encapsulating the domain model and rules.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PortEventType tells what happened to a Port.
+type PortEventType string
+
+const (
+	PortCreated PortEventType = "created"
+	PortUpdated PortEventType = "updated"
+	PortDeleted PortEventType = "deleted"
+)
+
+// PortEvent records a change of a stored Port.
+type PortEvent struct {
+	Type PortEventType
+	Key  string
+	Old  *Port // The Port before the change, nil for PortCreated.
+	New  *Port // The Port after the change, nil for PortDeleted.
+	Time time.Time
+	// ResumeToken identifies the position of the event in the feed, pass
+	// it to WatchPorts to receive the events after it.
+	ResumeToken string
+}
+
+// NewPortEvent returns the event of the Port under key changing from old to
+// new, either of which is nil if the Port did not exist before or after.
+func NewPortEvent(key string, old, new *Port) PortEvent {
+	event := PortEvent{Type: PortUpdated, Key: key, Old: old, New: new, Time: time.Now()}
+	switch {
+	case old == nil:
+		event.Type = PortCreated
+	case new == nil:
+		event.Type = PortDeleted
+	}
+	return event
+}
+
+// ErrInvalidResumeToken is returned by WatchPorts for a resume token it did
+// not hand out.
+var ErrInvalidResumeToken = errors.New("invalid resume token")
+
+// ErrResumeTokenExpired is returned by WatchPorts when the events after a
+// resume token are no longer retained, for instance after a restart. The
+// watcher has to read the current state again and watch without a token.
+var ErrResumeTokenExpired = errors.New("resume token expired")
+
+// ErrWatchUnavailable is returned by WatchPorts when no PortEventSource is
+// configured.
+var ErrWatchUnavailable = errors.New("watching ports is not available")
+
+// PortEventFilter narrows down the events passed on by WatchPorts.
+// Empty fields match every event; countries are compared ignoring case.
+type PortEventFilter struct {
+	Keys []string
+	// Country matches a Port in that country before or after the change,
+	// so watchers also learn about Ports leaving it.
+	Country string
+}
+
+// Matches reports whether event satisfies every criterion set on f.
+func (f PortEventFilter) Matches(event PortEvent) bool {
+	if len(f.Keys) > 0 && !slices.Contains(f.Keys, event.Key) {
+		return false
+	}
+	if f.Country != "" {
+		inOld := event.Old != nil && strings.EqualFold(event.Old.Country, f.Country)
+		inNew := event.New != nil && strings.EqualFold(event.New.Country, f.Country)
+		if !inOld && !inNew {
+			return false
+		}
+	}
+	return true
+}
+
+// PortEventSource is the feed of changes to the stored Ports, implemented by
+// the adapter layer.
+type PortEventSource interface {
+	// Watch calls fn with every event after resumeToken, in the order the
+	// changes were stored, until ctx is done or fn fails. An empty
+	// resumeToken starts with the next change.
+	Watch(ctx context.Context, resumeToken string, fn func(PortEvent) error) error
+}
+
+// WatchPorts calls fn with every change to a Port matching filter after
+// resumeToken, until ctx is done or fn fails, and returns the reason it
+// stopped. An empty resumeToken starts with the next change; a watcher
+// reconnecting passes the ResumeToken of the last event it handled.
+func (s StorePortRepository) WatchPorts(ctx context.Context, filter PortEventFilter, resumeToken string, fn func(PortEvent) error) error {
+	if s.Events == nil {
+		return ErrWatchUnavailable
+	}
+
+	err := s.Events.Watch(ctx, resumeToken, func(event PortEvent) error {
+		if !filter.Matches(event) {
+			return nil
+		}
+		return fn(event)
+	})
+	if err != nil {
+		return fmt.Errorf("method of PortRepository WatchPorts can not Watch events: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/domain"
+)
+
+func TestNewPortEvent(t *testing.T) {
+	port := domain.Port{Key: "NLRTM"}
+
+	assert.Equal(t, domain.PortCreated, domain.NewPortEvent("NLRTM", nil, &port).Type)
+	assert.Equal(t, domain.PortUpdated, domain.NewPortEvent("NLRTM", &port, &port).Type)
+	assert.Equal(t, domain.PortDeleted, domain.NewPortEvent("NLRTM", &port, nil).Type)
+}
+
+func TestPortEventFilter_Matches(t *testing.T) {
+	dutch := domain.Port{Key: "NLRTM", Country: "Netherlands"}
+	belgian := domain.Port{Key: "NLRTM", Country: "Belgium"}
+	moved := domain.NewPortEvent("NLRTM", &dutch, &belgian)
+
+	assert.True(t, domain.PortEventFilter{}.Matches(moved))
+	assert.True(t, domain.PortEventFilter{Keys: []string{"DEHAM", "NLRTM"}}.Matches(moved))
+	assert.False(t, domain.PortEventFilter{Keys: []string{"DEHAM"}}.Matches(moved))
+	assert.True(t, domain.PortEventFilter{Country: "netherlands"}.Matches(moved))
+	assert.True(t, domain.PortEventFilter{Country: "Belgium"}.Matches(moved))
+	assert.False(t, domain.PortEventFilter{Country: "Germany"}.Matches(moved))
+	assert.False(t, domain.PortEventFilter{Keys: []string{"NLRTM"}, Country: "Germany"}.Matches(moved))
+}
+
+func TestStorePortRepository_WatchPortsWithoutEvents(t *testing.T) {
+	repo := domain.StorePortRepository{Data: database.NewMemDB[domain.Port]()}
+
+	err := repo.WatchPorts(context.Background(), domain.PortEventFilter{}, "", func(domain.PortEvent) error { return nil })
+	assert.ErrorIs(t, err, domain.ErrWatchUnavailable)
+}
@@ -0,0 +1,126 @@
+package domain
+
+// Package domain contains the core business entities and logic.
+// In Domain-Driven Design (DDD), this package is the heart of the business logic,
+// encapsulating the domain model and rules.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ports-service/internal/ports"
+)
+
+// PortRevision is a version of a Port as it was stored.
+type PortRevision struct {
+	Revision uint64    `json:"revision"`
+	Time     time.Time `json:"time"`
+	// Port is the stored Port, nil if the revision deleted it.
+	Port *Port `json:"port,omitempty"`
+}
+
+// PortHistoryStore keeps the revisions of every stored Port, implemented by
+// the adapter layer.
+type PortHistoryStore interface {
+	// Append adds revision to the history of the Port under key, replacing
+	// the revisions from the same one on, so it can be retried.
+	Append(ctx context.Context, key string, revision PortRevision) error
+	// History returns the revisions of the Port under key, oldest first,
+	// or none if it never was stored.
+	History(ctx context.Context, key string) ([]PortRevision, error)
+}
+
+// WriteRecorder is a PortHistoryStore that is also a ports.Store of Ports
+// and appends the revision of every Port written to it in the same
+// transaction, like the SQL store. A StorePortRepository using one as both
+// Data and History leaves recording revisions to it.
+type WriteRecorder interface {
+	PortHistoryStore
+	RecordsWrites()
+}
+
+// ErrHistoryUnavailable is returned by GetPortHistory and GetPortAsOf when
+// no PortHistoryStore is configured.
+var ErrHistoryUnavailable = errors.New("port history is not available")
+
+// GetPortHistory returns every revision of the Port stored under key, oldest
+// first. The returned error matches ports.ErrNotFound when the Port never
+// was stored.
+func (s StorePortRepository) GetPortHistory(ctx context.Context, key string) ([]PortRevision, error) {
+	if s.History == nil {
+		return nil, ErrHistoryUnavailable
+	}
+
+	revisions, err := s.History.History(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("method of PortRepository GetPortHistory can not get History: %w", err)
+	}
+	if len(revisions) == 0 {
+		return nil, &ports.NotFoundError{Key: key}
+	}
+
+	return revisions, nil
+}
+
+// GetPortAsOf returns the Port stored under key at the given time. The
+// returned error matches ports.ErrNotFound when there was none, because it
+// was stored later or had been deleted by then.
+func (s StorePortRepository) GetPortAsOf(ctx context.Context, key string, asOf time.Time) (Port, error) {
+	revisions, err := s.GetPortHistory(ctx, key)
+	if err != nil {
+		return Port{}, err
+	}
+
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if revisions[i].Time.After(asOf) {
+			continue
+		}
+		if revisions[i].Port == nil {
+			break
+		}
+		return *revisions[i].Port, nil
+	}
+	return Port{}, &ports.NotFoundError{Key: key}
+}
+
+// now returns the time changes are stamped with.
+func (s StorePortRepository) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// lastRevision returns the latest revision in the history of the Port under
+// key, 0 if there is none or no history is kept.
+func (s StorePortRepository) lastRevision(ctx context.Context, key string) (uint64, error) {
+	if s.History == nil {
+		return 0, nil
+	}
+	revisions, err := s.History.History(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("method of PortRepository can not get History of %s: %w", key, err)
+	}
+	if len(revisions) == 0 {
+		return 0, nil
+	}
+	return revisions[len(revisions)-1].Revision, nil
+}
+
+// record appends revision to the history of the Port under key, if one is
+// kept and Data does not record it itself. It is called before the change is
+// stored, so failing to record it fails the change.
+func (s StorePortRepository) record(ctx context.Context, key string, revision PortRevision) error {
+	if s.History == nil {
+		return nil
+	}
+	if _, ok := s.History.(WriteRecorder); ok && any(s.History) == any(s.Data) {
+		return nil
+	}
+	if err := s.History.Append(ctx, key, revision); err != nil {
+		return fmt.Errorf("method of PortRepository can not Append revision %d of %s to History: %w", revision.Revision, key, err)
+	}
+	return nil
+}
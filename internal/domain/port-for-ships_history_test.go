@@ -0,0 +1,118 @@
+package domain_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/domain"
+	"ports-service/internal/ports"
+)
+
+func TestStorePortRepository_History(t *testing.T) {
+	ctx := context.Background()
+	clock := testNow
+	repo := domain.StorePortRepository{
+		Data:    database.NewMemDB[domain.Port](),
+		History: database.NewHistoryStore(database.NewMemDB[[]domain.PortRevision]()),
+		Now: func() time.Time {
+			clock = clock.Add(time.Hour)
+			return clock
+		},
+	}
+
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}}
+	renamed := domain.Port{Key: "NLRTM", Name: "Rotterdam Europoort", Unlocs: []string{"NLRTM"}}
+	require.NoError(t, repo.Store(ctx, rotterdam))
+	require.NoError(t, repo.Store(ctx, rotterdam)) // Unchanged, no revision.
+	require.NoError(t, repo.Store(ctx, renamed))
+
+	got, err := repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), got.Revision)
+	require.NotNil(t, got.UpdatedAt)
+	assert.Equal(t, testNow.Add(2*time.Hour), *got.UpdatedAt)
+
+	require.NoError(t, repo.Delete(ctx, "NLRTM"))
+
+	revisions, err := repo.GetPortHistory(ctx, "NLRTM")
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	for i, revision := range revisions {
+		assert.Equal(t, uint64(i+1), revision.Revision)
+		assert.Equal(t, testNow.Add(time.Duration(i+1)*time.Hour), revision.Time)
+	}
+	assert.Equal(t, "Rotterdam", revisions[0].Port.Name)
+	assert.Equal(t, got, *revisions[1].Port)
+	assert.Nil(t, revisions[2].Port)
+
+	_, err = repo.GetPortHistory(ctx, "DEHAM")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+
+	// Before the first revision, at and between revisions, and once deleted.
+	_, err = repo.GetPortAsOf(ctx, "NLRTM", testNow)
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+	asOf, err := repo.GetPortAsOf(ctx, "NLRTM", testNow.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam", asOf.Name)
+	asOf, err = repo.GetPortAsOf(ctx, "NLRTM", testNow.Add(150*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam Europoort", asOf.Name)
+	_, err = repo.GetPortAsOf(ctx, "NLRTM", testNow.Add(3*time.Hour))
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+
+	// Stored again, the Port continues its revisions.
+	require.NoError(t, repo.Store(ctx, rotterdam))
+	got, err = repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), got.Revision)
+}
+
+func TestStorePortRepository_HistoryUnavailable(t *testing.T) {
+	repo := newTestRepository(t, domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}})
+
+	_, err := repo.GetPortHistory(context.Background(), "NLRTM")
+	assert.ErrorIs(t, err, domain.ErrHistoryUnavailable)
+	_, err = repo.GetPortAsOf(context.Background(), "NLRTM", time.Now())
+	assert.ErrorIs(t, err, domain.ErrHistoryUnavailable)
+}
+
+// failingHistory fails to Append while fail is set.
+type failingHistory struct {
+	domain.PortHistoryStore
+	fail bool
+}
+
+func (h *failingHistory) Append(ctx context.Context, key string, revision domain.PortRevision) error {
+	if h.fail {
+		return errors.New("history unavailable")
+	}
+	return h.PortHistoryStore.Append(ctx, key, revision)
+}
+
+func TestStorePortRepository_HistoryFailureStoresNothing(t *testing.T) {
+	ctx := context.Background()
+	history := &failingHistory{PortHistoryStore: database.NewHistoryStore(database.NewMemDB[[]domain.PortRevision]())}
+	repo := domain.StorePortRepository{Data: database.NewMemDB[domain.Port](), History: history}
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}}
+
+	history.fail = true
+	assert.Error(t, repo.Store(ctx, rotterdam))
+	_, err := repo.Get(ctx, "NLRTM")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+
+	// The retry stores the Port along with its first revision.
+	history.fail = false
+	outcome, err := repo.Upsert(ctx, rotterdam)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Created, outcome)
+	revisions, err := repo.GetPortHistory(ctx, "NLRTM")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, uint64(1), revisions[0].Revision)
+}
@@ -0,0 +1,116 @@
+package domain
+
+// Package domain contains the core business entities and logic.
+// In Domain-Driven Design (DDD), this package is the heart of the business logic,
+// encapsulating the domain model and rules.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"ports-service/internal/ports"
+)
+
+// PortField names a field of Port that Ports can be looked up by.
+type PortField string
+
+const (
+	FieldUnloc   PortField = "unlocs"
+	FieldCode    PortField = "code"
+	FieldCountry PortField = "country"
+	FieldAlias   PortField = "alias"
+)
+
+// LookupFields lists every PortField a StorePortRepository can look Ports up by.
+var LookupFields = []PortField{FieldUnloc, FieldCode, FieldCountry, FieldAlias}
+
+// Values returns the values port carries for f.
+func (f PortField) Values(port Port) []string {
+	switch f {
+	case FieldUnloc:
+		return port.Unlocs
+	case FieldCode:
+		return []string{port.Code}
+	case FieldCountry:
+		return []string{port.Country}
+	case FieldAlias:
+		return port.Alias
+	default:
+		return nil
+	}
+}
+
+// PortIndex is a secondary index over one PortField of the stored Ports,
+// implemented by the adapter layer.
+type PortIndex interface {
+	// Lookup returns the keys of the Ports carrying value, ignoring case, in
+	// ascending order.
+	Lookup(value string) []string
+}
+
+// FindByUnloc returns the Ports listing unloc among their UN/LOCODEs.
+func (s StorePortRepository) FindByUnloc(ctx context.Context, unloc string) ([]Port, error) {
+	return s.findBy(ctx, FieldUnloc, unloc)
+}
+
+// FindByCode returns the Ports with the given code.
+func (s StorePortRepository) FindByCode(ctx context.Context, code string) ([]Port, error) {
+	return s.findBy(ctx, FieldCode, code)
+}
+
+// FindByCountry returns the Ports located in country.
+func (s StorePortRepository) FindByCountry(ctx context.Context, country string) ([]Port, error) {
+	return s.findBy(ctx, FieldCountry, country)
+}
+
+// FindByAlias returns the Ports listing alias among their alternative names.
+func (s StorePortRepository) FindByAlias(ctx context.Context, alias string) ([]Port, error) {
+	return s.findBy(ctx, FieldAlias, alias)
+}
+
+// findBy returns the Ports carrying value for field, ordered by key. The
+// query is answered by the index of field when one is configured; otherwise
+// every stored Port is scanned.
+func (s StorePortRepository) findBy(ctx context.Context, field PortField, value string) ([]Port, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	index, ok := s.Indexes[field]
+	if !ok {
+		return s.scanFindBy(ctx, field, value)
+	}
+
+	keys := index.Lookup(value)
+	result := make([]Port, 0, len(keys))
+	for _, key := range keys {
+		port, err := s.Data.Get(ctx, key)
+		if errors.Is(err, ports.ErrNotFound) || (err == nil && port.Deleted()) {
+			continue // Deleted since the index was queried.
+		}
+		if err != nil {
+			return nil, fmt.Errorf("method of PortRepository FindBy %s can not Get data: %w", field, err)
+		}
+		result = append(result, port)
+	}
+	return result, nil
+}
+
+func (s StorePortRepository) scanFindBy(ctx context.Context, field PortField, value string) ([]Port, error) {
+	var result []Port
+	err := s.scan(ctx, func(port Port) {
+		for _, v := range field.Values(port) {
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				result = append(result, port)
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("method of PortRepository FindBy %s can not List data: %w", field, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/domain"
+)
+
+func portKeys(found []domain.Port) []string {
+	keys := make([]string, 0, len(found))
+	for _, port := range found {
+		keys = append(keys, port.Key)
+	}
+	return keys
+}
+
+func TestStorePortRepository_FindBy(t *testing.T) {
+	seed := []domain.Port{
+		{Key: "NLRTM", Name: "Rotterdam", Country: "Netherlands", Unlocs: []string{"NLRTM"}, Code: "42157"},
+		{Key: "NLAMS", Name: "Amsterdam", Country: "Netherlands", Unlocs: []string{"NLAMS", "NLZAA"}, Alias: []string{"Mokum"}},
+		{Key: "DEHAM", Name: "Hamburg", Country: "Germany", Unlocs: []string{"DEHAM"}, Code: "42861"},
+	}
+
+	indexes := make(map[domain.PortField]domain.PortIndex)
+	var memIndexes []database.Index[domain.Port]
+	for _, field := range domain.LookupFields {
+		index := database.NewFieldIndex(field.Values)
+		indexes[field] = index
+		memIndexes = append(memIndexes, index)
+	}
+	repositories := map[string]domain.StorePortRepository{
+		"WithIndexes": {Data: database.NewMemDB[domain.Port](memIndexes...), Indexes: indexes},
+		"Scanning":    {Data: database.NewMemDB[domain.Port]()},
+	}
+
+	for name, repo := range repositories {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, port := range seed {
+				require.NoError(t, repo.Store(ctx, port))
+			}
+
+			found, err := repo.FindByCountry(ctx, "netherlands")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"NLAMS", "NLRTM"}, portKeys(found))
+
+			found, err = repo.FindByUnloc(ctx, "NLZAA")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"NLAMS"}, portKeys(found))
+
+			found, err = repo.FindByCode(ctx, "42861")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"DEHAM"}, portKeys(found))
+
+			found, err = repo.FindByAlias(ctx, "MOKUM")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"NLAMS"}, portKeys(found))
+
+			// An overwrite moves the Port to its new values.
+			moved := seed[0]
+			moved.Country = "Belgium"
+			require.NoError(t, repo.Store(ctx, moved))
+			found, err = repo.FindByCountry(ctx, "Netherlands")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"NLAMS"}, portKeys(found))
+			found, err = repo.FindByCountry(ctx, "Belgium")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"NLRTM"}, portKeys(found))
+
+			require.NoError(t, repo.Delete(ctx, "DEHAM"))
+			found, err = repo.FindByCode(ctx, "42861")
+			require.NoError(t, err)
+			assert.Empty(t, found)
+
+			found, err = repo.FindByCode(ctx, "")
+			require.NoError(t, err)
+			assert.Empty(t, found)
+		})
+	}
+}
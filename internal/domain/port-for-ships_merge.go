@@ -0,0 +1,128 @@
+package domain
+
+// Package domain contains the core business entities and logic.
+// In Domain-Driven Design (DDD), this package is the heart of the business logic,
+// encapsulating the domain model and rules.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"ports-service/internal/ports"
+)
+
+const (
+	FieldName        PortField = "name"
+	FieldCity        PortField = "city"
+	FieldRegions     PortField = "regions"
+	FieldCoordinates PortField = "coordinates"
+	FieldProvince    PortField = "province"
+	FieldTimezone    PortField = "timezone"
+)
+
+// MergeFields lists every PortField a partial update can set.
+var MergeFields = []PortField{
+	FieldName, FieldCity, FieldCountry, FieldAlias, FieldRegions,
+	FieldCoordinates, FieldProvince, FieldTimezone, FieldUnloc, FieldCode,
+}
+
+// Merge returns p with the given fields taken from patch and all others
+// kept. A field not listed in MergeFields yields a *ValidationError.
+func (p Port) Merge(patch Port, fields []PortField) (Port, error) {
+	var invalid []FieldError
+	for _, field := range fields {
+		switch field {
+		case FieldName:
+			p.Name = patch.Name
+		case FieldCity:
+			p.City = patch.City
+		case FieldCountry:
+			p.Country = patch.Country
+		case FieldAlias:
+			p.Alias = patch.Alias
+		case FieldRegions:
+			p.Regions = patch.Regions
+		case FieldCoordinates:
+			p.Coordinates = patch.Coordinates
+		case FieldProvince:
+			p.Province = patch.Province
+		case FieldTimezone:
+			p.Timezone = patch.Timezone
+		case FieldUnloc:
+			p.Unlocs = patch.Unlocs
+		case FieldCode:
+			p.Code = patch.Code
+		default:
+			invalid = append(invalid, FieldError{Field: string(field), Reason: "can not be merged"})
+		}
+	}
+	if len(invalid) > 0 {
+		return p, &ValidationError{Key: patch.Key, Fields: invalid}
+	}
+	return p, nil
+}
+
+// PortPatch is a partial update of a Port decoded from a JSON merge patch
+// (RFC 7396): the fields present in the JSON replace those of the stored
+// Port, null clears them and absent ones are kept.
+type PortPatch struct {
+	Key    string      // Key of the patched Port.
+	Port   Port        // Values of Fields, the other fields are zero.
+	Fields []PortField // The fields present in the JSON, in MergeFields order.
+}
+
+// UnmarshalJSON decodes a JSON object of Port fields. Unknown members are
+// ignored like they are when decoding a Port.
+func (p *PortPatch) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	var port Port
+	if err := json.Unmarshal(data, &port); err != nil {
+		return err
+	}
+
+	p.Port = port
+	p.Fields = nil
+	for _, field := range MergeFields {
+		if _, ok := members[string(field)]; ok {
+			p.Fields = append(p.Fields, field)
+		}
+	}
+	p.Key = port.Key
+	return nil
+}
+
+// Merge updates the given fields of the Port stored under patch.Key with
+// those of patch and persists the result, reporting the outcome. Without a
+// stored Port, or for a deleted one, the patch is applied to an empty one.
+// The result has to satisfy the domain rules like a Port passed to Store.
+func (s StorePortRepository) Merge(ctx context.Context, patch Port, fields []PortField) (ports.UpsertOutcome, error) {
+	return s.merge(ctx, patch, fields, nil)
+}
+
+// merge implements Merge and MergeIfRevision.
+func (s StorePortRepository) merge(ctx context.Context, patch Port, fields []PortField, revision *uint64) (ports.UpsertOutcome, error) {
+	_, outcome, err := s.update(ctx, patch.Key, revision, func(old *Port) (Port, error) {
+		base := Port{Key: patch.Key}
+		if old != nil && !old.Deleted() {
+			base = *old
+		}
+		merged, err := base.Merge(patch, fields)
+		if err != nil {
+			return Port{}, err
+		}
+		return merged, merged.Validate()
+	})
+	if errors.Is(err, ErrInvalidPort) || errors.Is(err, ErrRevisionConflict) {
+		return 0, err
+	}
+	if err != nil {
+		return outcome, fmt.Errorf("method of PortRepository Merge can not Update data: %w", err)
+	}
+
+	return outcome, nil
+}
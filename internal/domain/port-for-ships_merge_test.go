@@ -0,0 +1,65 @@
+package domain_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/domain"
+	"ports-service/internal/ports"
+)
+
+func TestPort_Merge(t *testing.T) {
+	port := validPort()
+	patch := domain.Port{Key: port.Key, Timezone: "Europe/Berlin", Coordinates: &domain.GeoPoint{Lat: 1, Lon: 2}, Name: "ignored"}
+
+	merged, err := port.Merge(patch, []domain.PortField{domain.FieldTimezone, domain.FieldCoordinates})
+	require.NoError(t, err)
+	want := port
+	want.Timezone = "Europe/Berlin"
+	want.Coordinates = &domain.GeoPoint{Lat: 1, Lon: 2}
+	assert.Equal(t, want, merged)
+
+	_, err = port.Merge(patch, []domain.PortField{"key", "population"})
+	assert.ErrorIs(t, err, domain.ErrInvalidPort)
+	assert.Contains(t, err.Error(), "population")
+}
+
+func TestPortPatch_UnmarshalJSON(t *testing.T) {
+	var patch domain.PortPatch
+	require.NoError(t, json.Unmarshal([]byte(`{"key": "NLRTM", "timezone": null, "coordinates": [4.47917, 51.9225], "population": 1}`), &patch))
+
+	assert.Equal(t, "NLRTM", patch.Key)
+	assert.Equal(t, []domain.PortField{domain.FieldCoordinates, domain.FieldTimezone}, patch.Fields)
+	assert.Equal(t, &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}, patch.Port.Coordinates)
+	assert.Empty(t, patch.Port.Timezone)
+}
+
+func TestStorePortRepository_Merge(t *testing.T) {
+	ctx := context.Background()
+	repo := domain.StorePortRepository{Data: database.NewMemDB[domain.Port]()}
+	port := validPort()
+	require.NoError(t, repo.Store(ctx, port))
+
+	outcome, err := repo.Merge(ctx, domain.Port{Key: port.Key, Timezone: "Europe/Berlin"}, []domain.PortField{domain.FieldTimezone})
+	require.NoError(t, err)
+	assert.Equal(t, ports.Updated, outcome)
+	stored, err := repo.Get(ctx, port.Key)
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", stored.Timezone)
+	assert.Equal(t, port.Name, stored.Name)
+
+	// The merged Port has to be valid, an unknown Port only gets the patch.
+	_, err = repo.Merge(ctx, domain.Port{Key: port.Key, Timezone: "Mars/Olympus"}, []domain.PortField{domain.FieldTimezone})
+	assert.ErrorIs(t, err, domain.ErrInvalidPort)
+	_, err = repo.Merge(ctx, domain.Port{Key: "DEHAM", Name: "Hamburg"}, []domain.PortField{domain.FieldName})
+	assert.ErrorIs(t, err, domain.ErrInvalidPort)
+
+	outcome, err = repo.Merge(ctx, domain.Port{Key: "DEHAM", Name: "Hamburg", Unlocs: []string{"DEHAM"}},
+		[]domain.PortField{domain.FieldName, domain.FieldUnloc})
+	require.NoError(t, err)
+	assert.Equal(t, ports.Created, outcome)
+}
@@ -0,0 +1,119 @@
+package domain
+
+// Package domain contains the core business entities and logic.
+// In Domain-Driven Design (DDD), this package is the heart of the business logic,
+// encapsulating the domain model and rules.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"ports-service/internal/ports"
+)
+
+// PortDistance is the key of a Port together with its distance to a position.
+type PortDistance struct {
+	Key        string
+	DistanceKm float64
+}
+
+// NearbyPort is a Port together with its distance to a position.
+type NearbyPort struct {
+	Port       Port
+	DistanceKm float64
+}
+
+// PortLocator is a spatial index over the positions of the stored Ports,
+// implemented by the adapter layer.
+type PortLocator interface {
+	// Nearest returns up to n Ports within maxDistanceKm of point, closest
+	// first. A maxDistanceKm of zero or less means no limit.
+	Nearest(point GeoPoint, n int, maxDistanceKm float64) []PortDistance
+}
+
+// NearestPorts returns up to n Ports within maxDistanceKm of point, closest
+// first. A maxDistanceKm of zero or less means no limit. Ports without
+// coordinates are never returned.
+//
+// The query is answered by the Locator when one is configured; otherwise
+// every stored Port is scanned.
+func (s StorePortRepository) NearestPorts(ctx context.Context, point GeoPoint, n int, maxDistanceKm float64) ([]NearbyPort, error) {
+	if err := point.Validate(); err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	if s.Locator == nil {
+		return s.scanNearest(ctx, point, n, maxDistanceKm)
+	}
+
+	nearest := s.Locator.Nearest(point, n, maxDistanceKm)
+	result := make([]NearbyPort, 0, len(nearest))
+	for _, candidate := range nearest {
+		port, err := s.Data.Get(ctx, candidate.Key)
+		if errors.Is(err, ports.ErrNotFound) || (err == nil && port.Deleted()) {
+			continue // Deleted since the index was queried.
+		}
+		if err != nil {
+			return nil, fmt.Errorf("method of PortRepository NearestPorts can not Get data: %w", err)
+		}
+		result = append(result, NearbyPort{Port: port, DistanceKm: candidate.DistanceKm})
+	}
+	return result, nil
+}
+
+func (s StorePortRepository) scanNearest(ctx context.Context, point GeoPoint, n int, maxDistanceKm float64) ([]NearbyPort, error) {
+	var result []NearbyPort
+	err := s.scan(ctx, func(port Port) {
+		if port.Coordinates == nil {
+			return
+		}
+		distance := point.DistanceKm(*port.Coordinates)
+		if maxDistanceKm > 0 && distance > maxDistanceKm {
+			return
+		}
+		result = append(result, NearbyPort{Port: port, DistanceKm: distance})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("method of PortRepository NearestPorts can not List data: %w", err)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DistanceKm < result[j].DistanceKm
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result, nil
+}
+
+// scan calls fn for every stored Port, page by page, skipping tombstones.
+func (s StorePortRepository) scan(ctx context.Context, fn func(Port)) error {
+	return s.scanAll(ctx, func(port Port) {
+		if !port.Deleted() {
+			fn(port)
+		}
+	})
+}
+
+// scanAll calls fn with every stored Port and tombstone, ordered by key.
+func (s StorePortRepository) scanAll(ctx context.Context, fn func(Port)) error {
+	var pageToken string
+	for {
+		page, err := s.Data.List(ctx, ports.ListOptions[Port]{PageToken: pageToken})
+		if err != nil {
+			return err
+		}
+		for _, port := range page.Items {
+			fn(port)
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
@@ -0,0 +1,72 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/domain"
+)
+
+func TestGeoPoint_DistanceKm(t *testing.T) {
+	rotterdam := domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}
+	hamburg := domain.GeoPoint{Lat: 53.551085, Lon: 9.993682}
+	sydney := domain.GeoPoint{Lat: -33.8688, Lon: 151.2093}
+
+	assert.InDelta(t, 0, rotterdam.DistanceKm(rotterdam), 1e-9)
+	assert.InDelta(t, 412, rotterdam.DistanceKm(hamburg), 2)
+	assert.InDelta(t, rotterdam.DistanceKm(hamburg), hamburg.DistanceKm(rotterdam), 1e-9)
+	assert.InDelta(t, 16650, rotterdam.DistanceKm(sydney), 50)
+}
+
+func nearbyKeys(nearby []domain.NearbyPort) []string {
+	keys := make([]string, 0, len(nearby))
+	for _, n := range nearby {
+		keys = append(keys, n.Port.Key)
+	}
+	return keys
+}
+
+func TestStorePortRepository_NearestPorts(t *testing.T) {
+	seed := []domain.Port{
+		{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}, Coordinates: &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}},
+		{Key: "NLAMS", Name: "Amsterdam", Unlocs: []string{"NLAMS"}, Coordinates: &domain.GeoPoint{Lat: 52.3676, Lon: 4.90414}},
+		{Key: "DEHAM", Name: "Hamburg", Unlocs: []string{"DEHAM"}, Coordinates: &domain.GeoPoint{Lat: 53.551085, Lon: 9.993682}},
+		{Key: "BEANR", Name: "Antwerp", Unlocs: []string{"BEANR"}},
+	}
+	geoIndex := database.NewGeoIndex()
+	repositories := map[string]domain.StorePortRepository{
+		"WithLocator": {Data: database.NewMemDB[domain.Port](geoIndex), Locator: geoIndex},
+		"Scanning":    {Data: database.NewMemDB[domain.Port]()},
+	}
+
+	for name, repo := range repositories {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, port := range seed {
+				require.NoError(t, repo.Store(ctx, port))
+			}
+			// Just outside Rotterdam.
+			point := domain.GeoPoint{Lat: 51.95, Lon: 4.5}
+
+			nearby, err := repo.NearestPorts(ctx, point, 2, 0)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"NLRTM", "NLAMS"}, nearbyKeys(nearby))
+			assert.InDelta(t, 3.4, nearby[0].DistanceKm, 0.1)
+			assert.Equal(t, "Rotterdam", nearby[0].Port.Name)
+
+			nearby, err = repo.NearestPorts(ctx, point, 10, 100)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"NLRTM", "NLAMS"}, nearbyKeys(nearby))
+
+			nearby, err = repo.NearestPorts(ctx, point, 10, 0)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"NLRTM", "NLAMS", "DEHAM"}, nearbyKeys(nearby))
+
+			_, err = repo.NearestPorts(ctx, domain.GeoPoint{Lat: 91}, 10, 0)
+			assert.ErrorIs(t, err, domain.ErrInvalidGeoPoint)
+		})
+	}
+}
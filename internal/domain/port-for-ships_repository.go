@@ -6,7 +6,10 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"ports-service/internal/ports"
 )
@@ -30,19 +33,282 @@ import (
 type PortRepository interface {
 	// Store persists a Port aggregate, handling its state storage in a way
 	// that is consistent with the domain model's invariants and business rules.
+	// A Port violating those rules is rejected with an error matching ErrInvalidPort.
 	// The use of context.Context allows for operation cancellation, deadlines,
 	// and passing request-scoped values, making the method more robust and flexible.
 	Store(context.Context, Port) error
+
+	// Upsert stores a Port like Store and reports whether it was created,
+	// updated or left unchanged because an equal Port is stored already.
+	Upsert(context.Context, Port) (ports.UpsertOutcome, error)
+
+	// Merge updates only the given fields of the Port stored under the key
+	// of patch, creating it if needed, and reports the outcome. The merged
+	// Port is validated like one passed to Store.
+	Merge(ctx context.Context, patch Port, fields []PortField) (ports.UpsertOutcome, error)
+
+	// UpsertIfRevision and MergeIfRevision are Upsert and Merge for writers
+	// that read the Port first: they only write if the stored Port still has
+	// the given revision, 0 meaning none is stored, and otherwise fail with
+	// an error matching ErrRevisionConflict.
+	UpsertIfRevision(ctx context.Context, port Port, revision uint64) (ports.UpsertOutcome, error)
+	MergeIfRevision(ctx context.Context, patch Port, fields []PortField, revision uint64) (ports.UpsertOutcome, error)
+
+	// Get returns the Port stored under key. The returned error matches
+	// ports.ErrNotFound when no such Port exists.
+	Get(ctx context.Context, key string) (Port, error)
+
+	// Delete removes the Port stored under key, keeping a tombstone to
+	// restore it from. The returned error matches ports.ErrNotFound when no
+	// such Port exists.
+	Delete(ctx context.Context, key string) error
+
+	// Restore brings back the deleted Port whose tombstone is stored under
+	// key, as a new revision. The returned error matches ports.ErrNotFound
+	// when there is no such tombstone, or ErrNotDeleted when the Port is
+	// not deleted.
+	Restore(ctx context.Context, key string) (Port, error)
+
+	// DeleteIfRevision and RestoreIfRevision are Delete and Restore that
+	// only write if the stored Port, or for RestoreIfRevision its tombstone,
+	// still has the given revision, and otherwise fail with an error
+	// matching ErrRevisionConflict.
+	DeleteIfRevision(ctx context.Context, key string, revision uint64) error
+	RestoreIfRevision(ctx context.Context, key string, revision uint64) (Port, error)
+
+	// GetIncludingDeleted returns the Port stored under key like Get, or
+	// its tombstone if it is deleted.
+	GetIncludingDeleted(ctx context.Context, key string) (Port, error)
+
+	// List returns Ports ordered by key, one page at a time. Only Ports
+	// matching filter are returned.
+	List(ctx context.Context, filter PortFilter, pageToken string, pageSize int) (ports.Page[Port], error)
+
+	// NearestPorts returns up to n Ports within maxDistanceKm of point,
+	// closest first. A maxDistanceKm of zero or less means no limit.
+	NearestPorts(ctx context.Context, point GeoPoint, n int, maxDistanceKm float64) ([]NearbyPort, error)
+
+	// FindByUnloc, FindByCode, FindByCountry and FindByAlias return the Ports
+	// carrying the given value in the respective field, ordered by key.
+	// Values are compared ignoring case; no match yields an empty result.
+	FindByUnloc(ctx context.Context, unloc string) ([]Port, error)
+	FindByCode(ctx context.Context, code string) ([]Port, error)
+	FindByCountry(ctx context.Context, country string) ([]Port, error)
+	FindByAlias(ctx context.Context, alias string) ([]Port, error)
+
+	// SearchPorts returns up to n Ports whose name, city, alias or province
+	// match query, best match first. Matching ignores case and diacritics
+	// and tolerates prefixes and typos.
+	SearchPorts(ctx context.Context, query string, n int) ([]PortMatch, error)
+
+	// SearchPortsIncludingDeleted is SearchPorts also matching the
+	// tombstones of deleted Ports.
+	SearchPortsIncludingDeleted(ctx context.Context, query string, n int) ([]PortMatch, error)
+
+	// WatchPorts calls fn with every PortEvent matching filter that happens
+	// after resumeToken, until ctx is done or fn fails. The returned error
+	// matches ErrInvalidResumeToken or ErrResumeTokenExpired when the
+	// watch cannot resume from resumeToken.
+	WatchPorts(ctx context.Context, filter PortEventFilter, resumeToken string, fn func(PortEvent) error) error
+
+	// GetPortHistory returns every revision of the Port stored under key,
+	// oldest first, including the ones deleting it.
+	GetPortHistory(ctx context.Context, key string) ([]PortRevision, error)
+
+	// GetPortAsOf returns the Port stored under key at the given time. The
+	// returned error matches ports.ErrNotFound when there was none.
+	GetPortAsOf(ctx context.Context, key string, asOf time.Time) (Port, error)
+}
+
+// PortFilter narrows down the Ports returned by PortRepository.List.
+// Empty fields match every Port; comparisons ignore case.
+type PortFilter struct {
+	Country string
+	City    string
+	// IncludeDeleted also matches the tombstones of deleted Ports.
+	IncludeDeleted bool
+}
+
+// Matches reports whether port satisfies every criterion set on f.
+func (f PortFilter) Matches(port Port) bool {
+	if port.Deleted() && !f.IncludeDeleted {
+		return false
+	}
+	if f.Country != "" && !strings.EqualFold(f.Country, port.Country) {
+		return false
+	}
+	if f.City != "" && !strings.EqualFold(f.City, port.City) {
+		return false
+	}
+	return true
+}
+
+// matchesAll reports whether f matches every stored Port and tombstone.
+func (f PortFilter) matchesAll() bool {
+	return f == PortFilter{IncludeDeleted: true}
 }
 
 type StorePortRepository struct {
 	Data ports.Store[Port]
+	// Locator answers NearestPorts when set, it has to index the same
+	// Ports as Data. Without it NearestPorts scans all of Data.
+	Locator PortLocator
+	// Indexes answer the FindBy lookups of their PortField, each has to
+	// index the same Ports as Data. Lookups by a field without an index
+	// scan all of Data.
+	Indexes map[PortField]PortIndex
+	// Searcher answers SearchPorts when set, it has to index the same Ports
+	// as Data. Without it SearchPorts scans all of Data.
+	Searcher PortSearcher
+	// Events feeds WatchPorts, it has to record the changes to Data.
+	// Without it WatchPorts fails with ErrWatchUnavailable.
+	Events PortEventSource
+	// History keeps every revision of the stored Ports for GetPortHistory
+	// and GetPortAsOf. Without it they fail with ErrHistoryUnavailable.
+	History PortHistoryStore
+	// Now stamps the stored revisions, time.Now if nil.
+	Now func() time.Time
 }
 
+// Store validates port and persists it. A Port violating the domain rules is
+// rejected with a *ValidationError (matching ErrInvalidPort).
 func (s StorePortRepository) Store(ctx context.Context, port Port) error {
-	if err := s.Data.Set(ctx, port.Key, port); err != nil {
-		return fmt.Errorf("method of PortRepository Store can not Set data: %w", err)
+	_, err := s.Upsert(ctx, port)
+	return err
+}
+
+// Upsert validates port and persists it like Store, reporting the outcome.
+// Storing a Port equal to the stored one writes nothing, so no PortEvent is
+// emitted for it.
+func (s StorePortRepository) Upsert(ctx context.Context, port Port) (ports.UpsertOutcome, error) {
+	if err := port.Validate(); err != nil {
+		return 0, err
+	}
+	port = port.unstamped()
+
+	_, outcome, err := s.update(ctx, port.Key, nil, func(*Port) (Port, error) { return port, nil })
+	if err != nil {
+		return outcome, fmt.Errorf("method of PortRepository Upsert can not Upsert data: %w", err)
+	}
+
+	return outcome, nil
+}
+
+// unstamped returns p without the fields update stamps, so a writer can
+// neither choose them nor delete a Port by passing a tombstone; only Delete
+// creates tombstones.
+func (p Port) unstamped() Port {
+	p.Revision = 0
+	p.UpdatedAt = nil
+	p.DeletedAt = nil
+	return p
+}
+
+// update stores the Port fn derives from the stored one or its tombstone,
+// if the stored Port has the expected revision or none is expected; a
+// tombstone counts as no Port. Unless the Port equals the stored one, it is
+// stamped with the next revision and recorded in History, and a tombstone
+// fn returns is stamped as deleted at the same time. A Port stored again
+// after a deletion continues the revisions of its tombstone or History. The
+// revision is recorded within the same store operation, so History never
+// misses a stored revision.
+func (s StorePortRepository) update(ctx context.Context, key string, expected *uint64, fn func(old *Port) (Port, error)) (Port, ports.UpsertOutcome, error) {
+	var stored Port
+	var revived bool
+	outcome, err := s.Data.Update(ctx, key, func(old *Port) (Port, error) {
+		current := old
+		if old != nil && old.Deleted() {
+			current = nil
+		}
+		if err := checkRevision(key, current, expected); err != nil {
+			return Port{}, err
+		}
+		port, err := fn(old)
+		if err != nil {
+			return Port{}, err
+		}
+		if old != nil && old.Equal(port) {
+			stored = *old
+			return *old, nil
+		}
+
+		if old != nil {
+			port.Revision = old.Revision + 1
+		} else if port.Revision, err = s.lastRevision(ctx, key); err != nil {
+			return Port{}, err
+		} else {
+			port.Revision++
+		}
+		now := s.now()
+		port.UpdatedAt = &now
+		if port.Deleted() && current != nil {
+			port.DeletedAt = &now
+		}
+		stored = port
+		revived = old != nil && current == nil && !port.Deleted()
+
+		revision := PortRevision{Revision: port.Revision, Time: now}
+		if !port.Deleted() {
+			revision.Port = &stored
+		}
+		return port, s.record(ctx, key, revision)
+	})
+	if err == nil && revived {
+		outcome = ports.Created
+	}
+	return stored, outcome, err
+}
+
+// Get returns the Port stored under key, not its tombstone.
+func (s StorePortRepository) Get(ctx context.Context, key string) (Port, error) {
+	port, err := s.Data.Get(ctx, key)
+	if err == nil && port.Deleted() {
+		err = &ports.NotFoundError{Key: key}
+	}
+	if err != nil {
+		return Port{}, fmt.Errorf("method of PortRepository Get can not Get data: %w", err)
+	}
+
+	return port, nil
+}
+
+// Delete replaces the Port stored under key with its tombstone, which reads
+// skip unless asked for deleted Ports, until it is restored, overwritten or
+// purged.
+func (s StorePortRepository) Delete(ctx context.Context, key string) error {
+	return s.delete(ctx, key, nil)
+}
+
+// delete implements Delete and DeleteIfRevision.
+func (s StorePortRepository) delete(ctx context.Context, key string, revision *uint64) error {
+	_, _, err := s.update(ctx, key, revision, func(old *Port) (Port, error) {
+		if old == nil || old.Deleted() {
+			return Port{}, &ports.NotFoundError{Key: key}
+		}
+		tombstone := *old
+		tombstone.DeletedAt = new(time.Time) // Stamped by update.
+		return tombstone, nil
+	})
+	if errors.Is(err, ErrRevisionConflict) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("method of PortRepository Delete can not Delete data: %w", err)
 	}
 
 	return nil
 }
+
+func (s StorePortRepository) List(ctx context.Context, filter PortFilter, pageToken string, pageSize int) (ports.Page[Port], error) {
+	opts := ports.ListOptions[Port]{PageToken: pageToken, PageSize: pageSize}
+	if !filter.matchesAll() {
+		opts.Filter = filter.Matches
+	}
+
+	page, err := s.Data.List(ctx, opts)
+	if err != nil {
+		return ports.Page[Port]{}, fmt.Errorf("method of PortRepository List can not List data: %w", err)
+	}
+
+	return page, nil
+}
@@ -3,10 +3,13 @@ package domain_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"ports-service/internal/adapters/database"
 	"ports-service/internal/domain"
+	"ports-service/internal/ports"
 )
 
 // MockPortRepository is a mock implementation of the PortRepository interface
@@ -60,3 +63,86 @@ func TestSavePort(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 	assert.NoError(t, err)
 }
+
+// testNow is the time a test repository stamps changes with.
+var testNow = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestRepository(t *testing.T, seed ...domain.Port) domain.StorePortRepository {
+	t.Helper()
+	repo := domain.StorePortRepository{
+		Data: database.NewMemDB[domain.Port](),
+		Now:  func() time.Time { return testNow },
+	}
+	for _, port := range seed {
+		assert.NoError(t, repo.Store(context.Background(), port))
+	}
+	return repo
+}
+
+// stored returns port as first stored by a test repository.
+func stored(port domain.Port) domain.Port {
+	port.Revision = 1
+	updatedAt := testNow
+	port.UpdatedAt = &updatedAt
+	return port
+}
+
+func TestStorePortRepository_Get(t *testing.T) {
+	port := domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}}
+	repo := newTestRepository(t, port)
+
+	got, err := repo.Get(context.Background(), "NLRTM")
+	assert.NoError(t, err)
+	assert.Equal(t, stored(port), got)
+
+	_, err = repo.Get(context.Background(), "DEHAM")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+}
+
+func TestStorePortRepository_Delete(t *testing.T) {
+	repo := newTestRepository(t, domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}})
+
+	assert.NoError(t, repo.Delete(context.Background(), "NLRTM"))
+
+	_, err := repo.Get(context.Background(), "NLRTM")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+	assert.ErrorIs(t, repo.Delete(context.Background(), "NLRTM"), ports.ErrNotFound)
+}
+
+func TestStorePortRepository_List(t *testing.T) {
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", City: "Rotterdam", Country: "Netherlands", Unlocs: []string{"NLRTM"}}
+	amsterdam := domain.Port{Key: "NLAMS", Name: "Amsterdam", City: "Amsterdam", Country: "Netherlands", Unlocs: []string{"NLAMS"}}
+	hamburg := domain.Port{Key: "DEHAM", Name: "Hamburg", City: "Hamburg", Country: "Germany", Unlocs: []string{"DEHAM"}}
+	repo := newTestRepository(t, rotterdam, amsterdam, hamburg)
+	ctx := context.Background()
+	rotterdam, amsterdam, hamburg = stored(rotterdam), stored(amsterdam), stored(hamburg)
+
+	page, err := repo.List(ctx, domain.PortFilter{}, "", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Port{hamburg, amsterdam}, page.Items)
+	assert.Equal(t, "NLAMS", page.NextPageToken)
+
+	page, err = repo.List(ctx, domain.PortFilter{}, page.NextPageToken, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Port{rotterdam}, page.Items)
+	assert.Empty(t, page.NextPageToken)
+
+	page, err = repo.List(ctx, domain.PortFilter{Country: "netherlands"}, "", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Port{amsterdam, rotterdam}, page.Items)
+}
+
+func TestStorePortRepository_StoreRejectsInvalidPort(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	err := repo.Store(ctx, domain.Port{Key: "rotterdam", Name: "Rotterdam"})
+	assert.ErrorIs(t, err, domain.ErrInvalidPort)
+
+	var validationErr *domain.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "rotterdam", validationErr.Key)
+
+	_, err = repo.Get(ctx, "rotterdam")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+}
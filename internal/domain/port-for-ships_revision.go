@@ -0,0 +1,79 @@
+package domain
+
+// Package domain contains the core business entities and logic.
+// In Domain-Driven Design (DDD), this package is the heart of the business logic,
+// encapsulating the domain model and rules.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ports-service/internal/ports"
+)
+
+// ErrRevisionConflict is matched by the error of a write whose expected
+// revision is not the one stored.
+var ErrRevisionConflict = errors.New("revision conflict")
+
+// RevisionConflictError reports a write rejected because the Port under Key
+// changed since the writer read it.
+type RevisionConflictError struct {
+	Key      string
+	Expected uint64 // Revision the writer expected, 0 for no stored Port.
+	Actual   uint64 // Revision stored, 0 for no stored Port.
+}
+
+func (e *RevisionConflictError) Error() string {
+	return fmt.Sprintf("%v for %q: expected revision %d, stored revision is %d", ErrRevisionConflict, e.Key, e.Expected, e.Actual)
+}
+
+// Is makes errors.Is(err, ErrRevisionConflict) true for a
+// *RevisionConflictError.
+func (e *RevisionConflictError) Is(target error) bool {
+	return target == ErrRevisionConflict
+}
+
+// checkRevision returns a *RevisionConflictError unless old, the stored Port
+// or nil, has the expected revision. A nil expected revision always passes.
+func checkRevision(key string, old *Port, expected *uint64) error {
+	if expected == nil {
+		return nil
+	}
+	var actual uint64
+	if old != nil {
+		actual = old.Revision
+	}
+	if actual != *expected {
+		return &RevisionConflictError{Key: key, Expected: *expected, Actual: actual}
+	}
+	return nil
+}
+
+// UpsertIfRevision stores a Port like Upsert, provided the stored Port
+// still has the given revision; 0 expects none to be stored, or a deleted
+// one. Otherwise nothing is written and the returned error matches
+// ErrRevisionConflict.
+func (s StorePortRepository) UpsertIfRevision(ctx context.Context, port Port, revision uint64) (ports.UpsertOutcome, error) {
+	if err := port.Validate(); err != nil {
+		return 0, err
+	}
+	port = port.unstamped()
+
+	_, outcome, err := s.update(ctx, port.Key, &revision, func(*Port) (Port, error) { return port, nil })
+	if errors.Is(err, ErrRevisionConflict) {
+		return 0, err
+	}
+	if err != nil {
+		return outcome, fmt.Errorf("method of PortRepository UpsertIfRevision can not Upsert data: %w", err)
+	}
+
+	return outcome, nil
+}
+
+// MergeIfRevision updates the given fields like Merge, provided the stored
+// Port still has the given revision; 0 expects none to be stored. Otherwise
+// nothing is written and the returned error matches ErrRevisionConflict.
+func (s StorePortRepository) MergeIfRevision(ctx context.Context, patch Port, fields []PortField, revision uint64) (ports.UpsertOutcome, error) {
+	return s.merge(ctx, patch, fields, &revision)
+}
@@ -0,0 +1,116 @@
+package domain_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ports-service/internal/domain"
+	"ports-service/internal/ports"
+)
+
+func TestStorePortRepository_UpsertIfRevision(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}}
+
+	outcome, err := repo.UpsertIfRevision(ctx, rotterdam, 0)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Created, outcome)
+
+	// 0 expects no Port, which is stored now.
+	_, err = repo.UpsertIfRevision(ctx, rotterdam, 0)
+	var conflict *domain.RevisionConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, domain.RevisionConflictError{Key: "NLRTM", Expected: 0, Actual: 1}, *conflict)
+
+	renamed := rotterdam
+	renamed.Name = "Rotterdam Europoort"
+	outcome, err = repo.UpsertIfRevision(ctx, renamed, 1)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Updated, outcome)
+
+	// A writer that read revision 1 lost the race and writes nothing.
+	_, err = repo.UpsertIfRevision(ctx, rotterdam, 1)
+	assert.ErrorIs(t, err, domain.ErrRevisionConflict)
+	got, err := repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, "Rotterdam Europoort", got.Name)
+	assert.Equal(t, uint64(2), got.Revision)
+
+	_, err = repo.MergeIfRevision(ctx, domain.Port{Key: "NLRTM", Timezone: "Europe/Amsterdam"}, []domain.PortField{domain.FieldTimezone}, 1)
+	assert.ErrorIs(t, err, domain.ErrRevisionConflict)
+	outcome, err = repo.MergeIfRevision(ctx, domain.Port{Key: "NLRTM", Timezone: "Europe/Amsterdam"}, []domain.PortField{domain.FieldTimezone}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Updated, outcome)
+
+	// An invalid Port is rejected before its revision is checked.
+	_, err = repo.UpsertIfRevision(ctx, domain.Port{Key: "NLRTM"}, 1)
+	assert.ErrorIs(t, err, domain.ErrInvalidPort)
+}
+
+func TestStorePortRepository_DeleteAndRestoreIfRevision(t *testing.T) {
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}}
+	repo := newTestRepository(t, rotterdam)
+	ctx := context.Background()
+
+	// Another writer changed the Port after it was read at revision 1.
+	renamed := rotterdam
+	renamed.Name = "Rotterdam Europoort"
+	require.NoError(t, repo.Store(ctx, renamed))
+	assert.ErrorIs(t, repo.DeleteIfRevision(ctx, "NLRTM", 1), domain.ErrRevisionConflict)
+	_, err := repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteIfRevision(ctx, "NLRTM", 2))
+
+	// The tombstone has revision 3.
+	_, err = repo.RestoreIfRevision(ctx, "NLRTM", 2)
+	var conflict *domain.RevisionConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, domain.RevisionConflictError{Key: "NLRTM", Expected: 2, Actual: 3}, *conflict)
+	_, err = repo.Get(ctx, "NLRTM")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+	restored, err := repo.RestoreIfRevision(ctx, "NLRTM", 3)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), restored.Revision)
+
+	assert.ErrorIs(t, repo.DeleteIfRevision(ctx, "DEHAM", 0), ports.ErrNotFound)
+}
+
+func TestStorePortRepository_UpsertIfRevisionRace(t *testing.T) {
+	repo := newTestRepository(t, domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}})
+	ctx := context.Background()
+
+	// Every writer read revision 1, only one of them may win.
+	const writers = 8
+	errs := make(chan error, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			port := domain.Port{Key: "NLRTM", Name: "Rotterdam " + string(rune('A'+i)), Unlocs: []string{"NLRTM"}}
+			_, err := repo.UpsertIfRevision(ctx, port, 1)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	var won int
+	for err := range errs {
+		if err == nil {
+			won++
+			continue
+		}
+		assert.True(t, errors.Is(err, domain.ErrRevisionConflict), err)
+	}
+	assert.Equal(t, 1, won)
+	got, err := repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), got.Revision)
+}
@@ -0,0 +1,272 @@
+package domain
+
+// Package domain contains the core business entities and logic.
+// In Domain-Driven Design (DDD), this package is the heart of the business logic,
+// encapsulating the domain model and rules.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"ports-service/internal/ports"
+)
+
+// SearchField is a field of Port covered by the full-text search, weighted
+// by how much a match in it says about the Port.
+type SearchField struct {
+	Name   string
+	Weight float64
+	values func(Port) []string
+}
+
+// SearchFields lists the fields SearchPorts matches against, in the order of
+// the token lists returned by PortSearchTokens.
+var SearchFields = []SearchField{
+	{Name: "name", Weight: 1, values: func(p Port) []string { return []string{p.Name} }},
+	{Name: "city", Weight: 0.8, values: func(p Port) []string { return []string{p.City} }},
+	{Name: "alias", Weight: 0.7, values: func(p Port) []string { return p.Alias }},
+	{Name: "province", Weight: 0.4, values: func(p Port) []string { return []string{p.Province} }},
+}
+
+// Scores MatchToken assigns, an exact match always ranks above a prefix
+// match, which always ranks above a fuzzy one.
+const (
+	exactMatchScore  = 1.0
+	prefixMatchScore = 0.6 // Up to 0.9, the more of the token the prefix covers.
+	fuzzyMatchScore  = 0.55
+	fuzzyEditPenalty = 0.15
+	// phraseBonus is added, times the field weight, when the whole query
+	// equals the whole value of a field.
+	phraseBonus = 0.5
+)
+
+// foldedRunes spells out letters that do not decompose into a base letter
+// and combining marks.
+var foldedRunes = map[rune]string{
+	'ø': "o", 'æ': "ae", 'œ': "oe", 'ß': "ss", 'đ': "d", 'ð': "d", 'ł': "l", 'þ': "th", 'ı': "i",
+}
+
+// SearchTokens folds text to lower case without diacritics and splits it
+// into words, so that "Abu Z¸aby", "ABU ZABY" and "abu-zaby" all yield
+// ["abu", "zaby"].
+func SearchTokens(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range norm.NFD.String(text) {
+		switch {
+		case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Sk, r):
+			// Combining and spacing marks belong to the letter they decorate.
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			r = unicode.ToLower(r)
+			if folded, ok := foldedRunes[r]; ok {
+				word.WriteString(folded)
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '’':
+			// "Baie d'Urfé" is searched for as "durfe" as often as "d urfe".
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// PortSearchTokens returns the tokens of every SearchFields entry of port.
+func PortSearchTokens(port Port) [][]string {
+	tokens := make([][]string, len(SearchFields))
+	for i, field := range SearchFields {
+		for _, value := range field.values(port) {
+			tokens[i] = append(tokens[i], SearchTokens(value)...)
+		}
+	}
+	return tokens
+}
+
+// maxEdits is the edit distance a query token of the given length may be
+// off by and still match: short tokens have to be typed right.
+func maxEdits(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// MatchToken scores how well the query token q matches the token t of a
+// Port: exactly, as a prefix, or within maxEdits typos. Zero means no match.
+func MatchToken(q, t string) float64 {
+	if q == t {
+		return exactMatchScore
+	}
+	qr, tr := []rune(q), []rune(t)
+	if strings.HasPrefix(t, q) {
+		return prefixMatchScore + 0.3*float64(len(qr))/float64(len(tr))
+	}
+	limit := maxEdits(len(qr))
+	if limit == 0 {
+		return 0
+	}
+	if d := editDistance(qr, tr, limit); d <= limit {
+		return fuzzyMatchScore - fuzzyEditPenalty*float64(d)
+	}
+	return 0
+}
+
+// editDistance returns the optimal string alignment distance of a and b:
+// insertions, deletions, substitutions and swaps of adjacent runes. Once
+// the distance is known to exceed limit, limit+1 is returned.
+func editDistance(a, b []rune, limit int) int {
+	if diff := len(a) - len(b); diff > limit || -diff > limit {
+		return limit + 1
+	}
+
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(b)]
+}
+
+// ScoreMatch ranks a Port, given as its PortSearchTokens, against the
+// tokens of a query. Every query token has to match some token of the Port
+// and contributes its best weighted MatchToken score; zero means the Port
+// does not match.
+func ScoreMatch(query []string, port [][]string) float64 {
+	if len(query) == 0 {
+		return 0
+	}
+
+	var score float64
+	for _, q := range query {
+		var best float64
+		for i, tokens := range port {
+			for _, t := range tokens {
+				best = max(best, SearchFields[i].Weight*MatchToken(q, t))
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		score += best
+	}
+
+	phrase := strings.Join(query, " ")
+	var bonus float64
+	for i, field := range SearchFields {
+		if strings.Join(port[i], " ") == phrase {
+			bonus = max(bonus, field.Weight*phraseBonus)
+		}
+	}
+	return score + bonus
+}
+
+// PortScore is the key of a Port together with its search relevance.
+type PortScore struct {
+	Key   string
+	Score float64
+}
+
+// PortMatch is a Port together with its search relevance, higher is better.
+type PortMatch struct {
+	Port  Port
+	Score float64
+}
+
+// PortSearcher is a full-text index over the SearchFields of the stored
+// Ports, implemented by the adapter layer. It has to rank Ports by
+// ScoreMatch.
+type PortSearcher interface {
+	// Search returns up to n Ports matching the query tokens, best first.
+	Search(query []string, n int) []PortScore
+}
+
+// SearchPorts returns up to n Ports matching query, best first. Every word
+// of query has to match a word of the Port's name, city, alias or province,
+// ignoring case and diacritics, exactly, as a prefix or with a few typos.
+//
+// The query is answered by the Searcher when one is configured; otherwise
+// every stored Port is scanned.
+func (s StorePortRepository) SearchPorts(ctx context.Context, query string, n int) ([]PortMatch, error) {
+	tokens := SearchTokens(query)
+	if len(tokens) == 0 || n <= 0 {
+		return nil, nil
+	}
+
+	if s.Searcher == nil {
+		return s.scanSearch(ctx, s.scan, tokens, n)
+	}
+
+	scores := s.Searcher.Search(tokens, n)
+	result := make([]PortMatch, 0, len(scores))
+	for _, candidate := range scores {
+		port, err := s.Data.Get(ctx, candidate.Key)
+		if errors.Is(err, ports.ErrNotFound) || (err == nil && port.Deleted()) {
+			continue // Deleted since the index was queried.
+		}
+		if err != nil {
+			return nil, fmt.Errorf("method of PortRepository SearchPorts can not Get data: %w", err)
+		}
+		result = append(result, PortMatch{Port: port, Score: candidate.Score})
+	}
+	return result, nil
+}
+
+func (s StorePortRepository) scanSearch(ctx context.Context, scan func(context.Context, func(Port)) error, tokens []string, n int) ([]PortMatch, error) {
+	var result []PortMatch
+	err := scan(ctx, func(port Port) {
+		if score := ScoreMatch(tokens, PortSearchTokens(port)); score > 0 {
+			result = append(result, PortMatch{Port: port, Score: score})
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("method of PortRepository SearchPorts can not List data: %w", err)
+	}
+
+	// scan visits Ports ordered by key, so ties stay ordered by key.
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Score > result[j].Score
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"ports-service/internal/domain"
+)
+
+func TestSearchTokens(t *testing.T) {
+	testCases := []struct {
+		text string
+		want []string
+	}{
+		{text: "Abu Z¸aby [Abu Dhabi]", want: []string{"abu", "zaby", "abu", "dhabi"}},
+		{text: "Düsseldorf", want: []string{"dusseldorf"}},
+		{text: "Eskifjørdur - høfn", want: []string{"eskifjordur", "hofn"}},
+		{text: "Baie d'Urfé", want: []string{"baie", "durfe"}},
+		{text: "Cap-Haïtien", want: []string{"cap", "haitien"}},
+		{text: " ", want: nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			assert.Equal(t, tc.want, domain.SearchTokens(tc.text))
+		})
+	}
+}
+
+func TestMatchToken(t *testing.T) {
+	exact := domain.MatchToken("rotterdam", "rotterdam")
+	prefix := domain.MatchToken("rotter", "rotterdam")
+	typo := domain.MatchToken("rotterdm", "rotterdam")
+	swapped := domain.MatchToken("rottedram", "rotterdam")
+	twoTypos := domain.MatchToken("rottrdm", "rotterdam")
+
+	assert.Greater(t, exact, prefix)
+	assert.Greater(t, prefix, typo)
+	assert.Greater(t, typo, 0.0)
+	assert.Equal(t, typo, swapped)
+	assert.Zero(t, twoTypos, "seven runes allow a single edit")
+	assert.Zero(t, domain.MatchToken("rtm", "rta"), "short tokens have to match exactly")
+	assert.Zero(t, domain.MatchToken("hamburg", "rotterdam"))
+}
+
+func TestStorePortRepository_SearchPorts(t *testing.T) {
+	repo := newTestRepository(t,
+		domain.Port{Key: "NLRTM", Name: "Rotterdam", City: "Rotterdam", Province: "Zuid-Holland", Unlocs: []string{"NLRTM"}},
+		domain.Port{Key: "NLAMS", Name: "Amsterdam", City: "Amsterdam", Province: "Noord-Holland", Unlocs: []string{"NLAMS"}},
+		domain.Port{Key: "NLSCE", Name: "Scheveningen", City: "Den Haag", Alias: []string{"The Hague"}, Province: "Zuid-Holland", Unlocs: []string{"NLSCE"}},
+	)
+	ctx := context.Background()
+
+	found, err := repo.SearchPorts(ctx, "rotterdm", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"NLRTM"}, matchKeys(found))
+
+	// Equal scores are ordered by key.
+	found, err = repo.SearchPorts(ctx, "Holland", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"NLAMS", "NLRTM", "NLSCE"}, matchKeys(found))
+
+	found, err = repo.SearchPorts(ctx, "zuid holland", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"NLRTM", "NLSCE"}, matchKeys(found))
+
+	found, err = repo.SearchPorts(ctx, "hague", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"NLSCE"}, matchKeys(found))
+
+	found, err = repo.SearchPorts(ctx, "--", 10)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func matchKeys(found []domain.PortMatch) []string {
+	keys := make([]string, 0, len(found))
+	for _, f := range found {
+		keys = append(keys, f.Port.Key)
+	}
+	return keys
+}
@@ -0,0 +1,147 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"ports-service/internal/domain"
+)
+
+func validPort() domain.Port {
+	return domain.Port{
+		Key:         "NLRTM",
+		Name:        "Rotterdam",
+		City:        "Rotterdam",
+		Country:     "Netherlands",
+		Coordinates: &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917},
+		Timezone:    "Europe/Amsterdam",
+		Unlocs:      []string{"NLRTM"},
+	}
+}
+
+func TestPortValidate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		modify     func(p *domain.Port)
+		wantFields []string
+	}{
+		{
+			name:   "Valid",
+			modify: func(p *domain.Port) {},
+		},
+		{
+			name:   "ValidWithDigitsInLocationCode",
+			modify: func(p *domain.Port) { p.Key, p.Unlocs = "DE2HB", []string{"DE2HB"} },
+		},
+		{
+			name:   "ValidWithoutOptionalFields",
+			modify: func(p *domain.Port) { p.Coordinates, p.Timezone = nil, "" },
+		},
+		{
+			name:       "LowercaseKey",
+			modify:     func(p *domain.Port) { p.Key, p.Unlocs = "nlrtm", []string{"nlrtm"} },
+			wantFields: []string{"key"},
+		},
+		{
+			name:       "KeyWithInvalidDigit",
+			modify:     func(p *domain.Port) { p.Key, p.Unlocs = "NLRT1", []string{"NLRT1"} },
+			wantFields: []string{"key"},
+		},
+		{
+			name:       "EmptyName",
+			modify:     func(p *domain.Port) { p.Name = " " },
+			wantFields: []string{"name"},
+		},
+		{
+			name:       "LatitudeOutOfRange",
+			modify:     func(p *domain.Port) { p.Coordinates = &domain.GeoPoint{Lat: 91, Lon: 4.47917} },
+			wantFields: []string{"coordinates"},
+		},
+		{
+			name:       "LongitudeOutOfRange",
+			modify:     func(p *domain.Port) { p.Coordinates = &domain.GeoPoint{Lat: 51.9225, Lon: -180.5} },
+			wantFields: []string{"coordinates"},
+		},
+		{
+			name:       "UnknownTimezone",
+			modify:     func(p *domain.Port) { p.Timezone = "America/Argentina" },
+			wantFields: []string{"timezone"},
+		},
+		{
+			name:       "UnlocsWithoutKey",
+			modify:     func(p *domain.Port) { p.Unlocs = []string{"NLAMS"} },
+			wantFields: []string{"unlocs"},
+		},
+		{
+			name: "SeveralViolations",
+			modify: func(p *domain.Port) {
+				p.Name = ""
+				p.Coordinates = &domain.GeoPoint{Lat: -100, Lon: 200}
+			},
+			wantFields: []string{"name", "coordinates", "coordinates"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			port := validPort()
+			tc.modify(&port)
+
+			err := port.Validate()
+			if len(tc.wantFields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, domain.ErrInvalidPort)
+			var validationErr *domain.ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, port.Key, validationErr.Key)
+				var fields []string
+				for _, field := range validationErr.Fields {
+					fields = append(fields, field.Field)
+				}
+				assert.Equal(t, tc.wantFields, fields)
+			}
+		})
+	}
+}
+
+func TestPort_Equal(t *testing.T) {
+	rotterdam := domain.Port{
+		Key:         "NLRTM",
+		Name:        "Rotterdam",
+		Alias:       []string{"Europoort"},
+		Coordinates: &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917},
+		Unlocs:      []string{"NLRTM"},
+	}
+
+	same := rotterdam
+	same.Coordinates = &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}
+	same.Regions = []string{}
+	assert.True(t, rotterdam.Equal(same), "nil and empty lists are alike, coordinates compare by value")
+
+	moved := rotterdam
+	moved.Coordinates = nil
+	assert.False(t, rotterdam.Equal(moved))
+	renamed := rotterdam
+	renamed.Alias = []string{"Maasvlakte"}
+	assert.False(t, rotterdam.Equal(renamed))
+}
+
+func TestPort_MarshalJSONStamps(t *testing.T) {
+	data, err := json.Marshal(validPort())
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "revision")
+	assert.NotContains(t, string(data), "updated_at")
+
+	updatedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	stored := validPort()
+	stored.Revision, stored.UpdatedAt = 1, &updatedAt
+	data, err = json.Marshal(stored)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"updated_at":"2024-03-01T12:00:00Z"`)
+}
@@ -0,0 +1,121 @@
+package domain
+
+// Package domain contains the core business entities and logic.
+// In Domain-Driven Design (DDD), this package is the heart of the business logic,
+// encapsulating the domain model and rules.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ports-service/internal/ports"
+)
+
+// ErrNotDeleted is matched by the error of Restore for a Port that is not
+// deleted.
+var ErrNotDeleted = errors.New("port is not deleted")
+
+// Restore brings back the deleted Port under key from its tombstone, as a
+// new revision, and returns it.
+func (s StorePortRepository) Restore(ctx context.Context, key string) (Port, error) {
+	return s.restore(ctx, key, nil)
+}
+
+// DeleteIfRevision deletes the Port under key like Delete, provided it
+// still has the given revision. Otherwise nothing is written and the
+// returned error matches ErrRevisionConflict.
+func (s StorePortRepository) DeleteIfRevision(ctx context.Context, key string, revision uint64) error {
+	return s.delete(ctx, key, &revision)
+}
+
+// RestoreIfRevision restores the Port under key like Restore, provided its
+// tombstone still has the given revision. Otherwise nothing is written and
+// the returned error matches ErrRevisionConflict.
+func (s StorePortRepository) RestoreIfRevision(ctx context.Context, key string, revision uint64) (Port, error) {
+	return s.restore(ctx, key, &revision)
+}
+
+// restore implements Restore and RestoreIfRevision. The revision is that of
+// the tombstone, which update would treat as no Port, so it is checked here.
+func (s StorePortRepository) restore(ctx context.Context, key string, revision *uint64) (Port, error) {
+	restored, _, err := s.update(ctx, key, nil, func(old *Port) (Port, error) {
+		if old == nil {
+			return Port{}, &ports.NotFoundError{Key: key}
+		}
+		if !old.Deleted() {
+			return Port{}, fmt.Errorf("%w: %q", ErrNotDeleted, key)
+		}
+		if err := checkRevision(key, old, revision); err != nil {
+			return Port{}, err
+		}
+		port := *old
+		port.DeletedAt = nil
+		return port, nil
+	})
+	if errors.Is(err, ErrRevisionConflict) {
+		return Port{}, err
+	}
+	if err != nil {
+		return Port{}, fmt.Errorf("method of PortRepository Restore can not Update data: %w", err)
+	}
+
+	return restored, nil
+}
+
+// GetIncludingDeleted returns the Port stored under key, or its tombstone.
+func (s StorePortRepository) GetIncludingDeleted(ctx context.Context, key string) (Port, error) {
+	port, err := s.Data.Get(ctx, key)
+	if err != nil {
+		return Port{}, fmt.Errorf("method of PortRepository GetIncludingDeleted can not Get data: %w", err)
+	}
+
+	return port, nil
+}
+
+// SearchPortsIncludingDeleted returns up to n Ports or tombstones matching
+// query, best first, like SearchPorts. As the Searcher does not index
+// tombstones it scans all of Data.
+func (s StorePortRepository) SearchPortsIncludingDeleted(ctx context.Context, query string, n int) ([]PortMatch, error) {
+	tokens := SearchTokens(query)
+	if len(tokens) == 0 || n <= 0 {
+		return nil, nil
+	}
+
+	return s.scanSearch(ctx, s.scanAll, tokens, n)
+}
+
+// PurgeDeleted removes the tombstones of the Ports deleted before the given
+// time for good and returns how many it removed. Their History is kept.
+func (s StorePortRepository) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	expired := func(port Port) bool {
+		return port.Deleted() && port.DeletedAt.Before(before)
+	}
+
+	var keys []string
+	err := s.scanAll(ctx, func(port Port) {
+		if expired(port) {
+			keys = append(keys, port.Key)
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("method of PortRepository PurgeDeleted can not List data: %w", err)
+	}
+
+	purged := 0
+	for _, key := range keys {
+		// Skips a Port restored or stored again since the scan.
+		deleted, err := s.Data.DeleteIf(ctx, key, expired)
+		if errors.Is(err, ports.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, fmt.Errorf("method of PortRepository PurgeDeleted can not Delete data: %w", err)
+		}
+		if deleted {
+			purged++
+		}
+	}
+	return purged, nil
+}
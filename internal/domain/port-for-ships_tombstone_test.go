@@ -0,0 +1,186 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ports-service/internal/adapters/database"
+	"ports-service/internal/domain"
+	"ports-service/internal/ports"
+)
+
+// newIndexedRepository is newTestRepository with every index and the clock
+// advancing an hour per change.
+func newIndexedRepository(t *testing.T, seed ...domain.Port) domain.StorePortRepository {
+	t.Helper()
+	geoIndex := database.NewGeoIndex()
+	searchIndex := database.NewSearchIndex()
+	indexes := []database.Index[domain.Port]{geoIndex, searchIndex}
+	fieldIndexes := make(map[domain.PortField]domain.PortIndex)
+	for _, field := range domain.LookupFields {
+		index := database.NewFieldIndex(field.Values)
+		indexes = append(indexes, index)
+		fieldIndexes[field] = index
+	}
+	clock := testNow
+	repo := domain.StorePortRepository{
+		Data:     database.NewMemDB[domain.Port](indexes...),
+		Locator:  geoIndex,
+		Indexes:  fieldIndexes,
+		Searcher: searchIndex,
+		Now: func() time.Time {
+			clock = clock.Add(time.Hour)
+			return clock
+		},
+	}
+	for _, port := range seed {
+		require.NoError(t, repo.Store(context.Background(), port))
+	}
+	return repo
+}
+
+func TestStorePortRepository_DeleteKeepsTombstone(t *testing.T) {
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Country: "Netherlands", Unlocs: []string{"NLRTM"},
+		Coordinates: &domain.GeoPoint{Lat: 51.9225, Lon: 4.47917}}
+	for name, repo := range map[string]domain.StorePortRepository{
+		"indexed":  newIndexedRepository(t, rotterdam),
+		"scanning": newTestRepository(t, rotterdam),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			require.NoError(t, repo.Delete(ctx, "NLRTM"))
+
+			_, err := repo.Get(ctx, "NLRTM")
+			assert.ErrorIs(t, err, ports.ErrNotFound)
+			assert.ErrorIs(t, repo.Delete(ctx, "NLRTM"), ports.ErrNotFound)
+			tombstone, err := repo.GetIncludingDeleted(ctx, "NLRTM")
+			require.NoError(t, err)
+			assert.True(t, tombstone.Deleted())
+			assert.Equal(t, tombstone.UpdatedAt, tombstone.DeletedAt)
+			assert.Equal(t, uint64(2), tombstone.Revision)
+
+			// Reads skip the tombstone unless asked for it.
+			page, err := repo.List(ctx, domain.PortFilter{}, "", 10)
+			require.NoError(t, err)
+			assert.Empty(t, page.Items)
+			page, err = repo.List(ctx, domain.PortFilter{Country: "netherlands", IncludeDeleted: true}, "", 10)
+			require.NoError(t, err)
+			assert.Equal(t, []domain.Port{tombstone}, page.Items)
+			found, err := repo.FindByUnloc(ctx, "NLRTM")
+			require.NoError(t, err)
+			assert.Empty(t, found)
+			nearby, err := repo.NearestPorts(ctx, *rotterdam.Coordinates, 1, 0)
+			require.NoError(t, err)
+			assert.Empty(t, nearby)
+			matches, err := repo.SearchPorts(ctx, "rotterdam", 10)
+			require.NoError(t, err)
+			assert.Empty(t, matches)
+			matches, err = repo.SearchPortsIncludingDeleted(ctx, "rotterdam", 10)
+			require.NoError(t, err)
+			require.Len(t, matches, 1)
+			assert.Equal(t, tombstone, matches[0].Port)
+
+			restored, err := repo.Restore(ctx, "NLRTM")
+			require.NoError(t, err)
+			assert.False(t, restored.Deleted())
+			assert.Equal(t, uint64(3), restored.Revision)
+			assert.True(t, rotterdam.Equal(restored))
+			found, err = repo.FindByUnloc(ctx, "NLRTM")
+			require.NoError(t, err)
+			assert.Equal(t, []domain.Port{restored}, found)
+
+			_, err = repo.Restore(ctx, "NLRTM")
+			assert.ErrorIs(t, err, domain.ErrNotDeleted)
+			_, err = repo.Restore(ctx, "DEHAM")
+			assert.ErrorIs(t, err, ports.ErrNotFound)
+		})
+	}
+}
+
+func TestStorePortRepository_StoreOverTombstone(t *testing.T) {
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}}
+	repo := newIndexedRepository(t, rotterdam)
+	ctx := context.Background()
+	require.NoError(t, repo.Delete(ctx, "NLRTM"))
+
+	// An equal Port revives the deleted one, continuing its revisions.
+	outcome, err := repo.Upsert(ctx, rotterdam)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Created, outcome)
+	got, err := repo.Get(ctx, "NLRTM")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), got.Revision)
+
+	// A patch of a deleted Port does not merge into the tombstone.
+	require.NoError(t, repo.Delete(ctx, "NLRTM"))
+	_, err = repo.Merge(ctx, domain.Port{Key: "NLRTM", Timezone: "Europe/Amsterdam"}, []domain.PortField{domain.FieldTimezone})
+	assert.ErrorIs(t, err, domain.ErrInvalidPort)
+
+	// A tombstone counts as no Port for an expected revision.
+	outcome, err = repo.UpsertIfRevision(ctx, rotterdam, 0)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Created, outcome)
+}
+
+func TestStorePortRepository_WritesIgnoreStamps(t *testing.T) {
+	ctx := context.Background()
+	rotterdam := domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}}
+	repo := newTestRepository(t, rotterdam)
+
+	// A Port read back from a snapshot carries the stamps of its source.
+	deletedAt := testNow.Add(-time.Hour)
+	stamped := func(port domain.Port) domain.Port {
+		port.Revision = 42
+		port.UpdatedAt = &deletedAt
+		port.DeletedAt = &deletedAt
+		return port
+	}
+
+	outcome, err := repo.Upsert(ctx, stamped(rotterdam))
+	require.NoError(t, err)
+	assert.Equal(t, ports.Unchanged, outcome, "the live Port is not deleted")
+	hamburg := domain.Port{Key: "DEHAM", Name: "Hamburg", Unlocs: []string{"DEHAM"}}
+	outcome, err = repo.UpsertIfRevision(ctx, stamped(hamburg), 0)
+	require.NoError(t, err)
+	assert.Equal(t, ports.Created, outcome)
+
+	for _, key := range []string{"NLRTM", "DEHAM"} {
+		got, err := repo.Get(ctx, key)
+		require.NoError(t, err, key)
+		assert.False(t, got.Deleted())
+		assert.Equal(t, uint64(1), got.Revision)
+		assert.Equal(t, &testNow, got.UpdatedAt)
+	}
+}
+
+func TestStorePortRepository_PurgeDeleted(t *testing.T) {
+	repo := newIndexedRepository(t,
+		domain.Port{Key: "NLRTM", Name: "Rotterdam", Unlocs: []string{"NLRTM"}},
+		domain.Port{Key: "DEHAM", Name: "Hamburg", Unlocs: []string{"DEHAM"}},
+		domain.Port{Key: "BEANR", Name: "Antwerp", Unlocs: []string{"BEANR"}},
+	)
+	ctx := context.Background()
+	require.NoError(t, repo.Delete(ctx, "NLRTM")) // Deleted at testNow + 4h.
+	require.NoError(t, repo.Delete(ctx, "DEHAM")) // Deleted at testNow + 5h.
+
+	purged, err := repo.PurgeDeleted(ctx, testNow.Add(5*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = repo.GetIncludingDeleted(ctx, "NLRTM")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+	_, err = repo.Restore(ctx, "NLRTM")
+	assert.ErrorIs(t, err, ports.ErrNotFound)
+	_, err = repo.Restore(ctx, "DEHAM")
+	assert.NoError(t, err)
+	_, err = repo.Get(ctx, "BEANR")
+	assert.NoError(t, err)
+
+	purged, err = repo.PurgeDeleted(ctx, testNow.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+}
@@ -19,15 +19,64 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	PortService_StreamPorts_FullMethodName = "/api.PortService/StreamPorts"
+	PortService_StreamPorts_FullMethodName     = "/api.PortService/StreamPorts"
+	PortService_StreamPortsBidi_FullMethodName = "/api.PortService/StreamPortsBidi"
+	PortService_GetPort_FullMethodName         = "/api.PortService/GetPort"
+	PortService_GetPortHistory_FullMethodName  = "/api.PortService/GetPortHistory"
+	PortService_ListPorts_FullMethodName       = "/api.PortService/ListPorts"
+	PortService_DeletePort_FullMethodName      = "/api.PortService/DeletePort"
+	PortService_RestorePort_FullMethodName     = "/api.PortService/RestorePort"
+	PortService_SearchNearby_FullMethodName    = "/api.PortService/SearchNearby"
+	PortService_FindPorts_FullMethodName       = "/api.PortService/FindPorts"
+	PortService_SearchPorts_FullMethodName     = "/api.PortService/SearchPorts"
+	PortService_WatchPorts_FullMethodName      = "/api.PortService/WatchPorts"
 )
 
 // PortServiceClient is the client API for PortService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type PortServiceClient interface {
-	// StreamPorts streams Port objects.
+	// StreamPorts streams Port objects. Once the client closes its side and
+	// every Port has been stored, the server answers with a StreamPortsResponse
+	// carrying the IngestSummary of the call, including the first rejections.
+	// ack is false if any Port was rejected. The call fails if the stream
+	// itself does, e.g. it was cancelled or stayed saturated, and if the store
+	// failed on a Port, with the IngestSummary as a status detail.
 	StreamPorts(ctx context.Context, opts ...grpc.CallOption) (PortService_StreamPortsClient, error)
+	// StreamPortsBidi streams Port objects and answers every request with a
+	// StreamPortsResponse carrying its uuid once the Port has been stored, or
+	// the reason it could not be.
+	StreamPortsBidi(ctx context.Context, opts ...grpc.CallOption) (PortService_StreamPortsBidiClient, error)
+	// GetPort returns a single Port by its key, as it is or as it was at a
+	// point in time.
+	GetPort(ctx context.Context, in *GetPortRequest, opts ...grpc.CallOption) (*GetPortResponse, error)
+	// GetPortHistory returns every stored revision of a Port, oldest first,
+	// including its deletions.
+	GetPortHistory(ctx context.Context, in *GetPortHistoryRequest, opts ...grpc.CallOption) (*GetPortHistoryResponse, error)
+	// ListPorts returns Port objects ordered by key, one page at a time.
+	ListPorts(ctx context.Context, in *ListPortsRequest, opts ...grpc.CallOption) (*ListPortsResponse, error)
+	// DeletePort removes a Port by its key. Reads skip the deleted Port unless
+	// they set include_deleted, and RestorePort brings it back, until it is
+	// purged once the server's retention window has passed.
+	DeletePort(ctx context.Context, in *DeletePortRequest, opts ...grpc.CallOption) (*DeletePortResponse, error)
+	// RestorePort brings back a deleted Port by its key. Fails with
+	// FAILED_PRECONDITION if the Port is not deleted.
+	RestorePort(ctx context.Context, in *RestorePortRequest, opts ...grpc.CallOption) (*RestorePortResponse, error)
+	// SearchNearby returns the Ports closest to a position, closest first.
+	SearchNearby(ctx context.Context, in *SearchNearbyRequest, opts ...grpc.CallOption) (*SearchNearbyResponse, error)
+	// FindPorts returns the Ports carrying a UN/LOCODE, code, country or alias,
+	// ordered by key.
+	FindPorts(ctx context.Context, in *FindPortsRequest, opts ...grpc.CallOption) (*FindPortsResponse, error)
+	// SearchPorts returns the Ports whose name, city, alias or province match
+	// a free-text query, best match first. Matching ignores case and
+	// diacritics and tolerates prefixes and typos.
+	SearchPorts(ctx context.Context, in *SearchPortsRequest, opts ...grpc.CallOption) (*SearchPortsResponse, error)
+	// WatchPorts streams the changes to the stored Ports, in the order they
+	// were stored, until the client cancels the call. A client reconnecting
+	// passes the resume_token of the last PortEvent it handled to receive the
+	// events after it. Fails with OUT_OF_RANGE once those are no longer
+	// retained, e.g. after a server restart.
+	WatchPorts(ctx context.Context, in *WatchPortsRequest, opts ...grpc.CallOption) (PortService_WatchPortsClient, error)
 }
 
 type portServiceClient struct {
@@ -72,12 +121,186 @@ func (x *portServiceStreamPortsClient) CloseAndRecv() (*StreamPortsResponse, err
 	return m, nil
 }
 
+func (c *portServiceClient) StreamPortsBidi(ctx context.Context, opts ...grpc.CallOption) (PortService_StreamPortsBidiClient, error) {
+	stream, err := c.cc.NewStream(ctx, &PortService_ServiceDesc.Streams[1], PortService_StreamPortsBidi_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &portServiceStreamPortsBidiClient{stream}
+	return x, nil
+}
+
+type PortService_StreamPortsBidiClient interface {
+	Send(*StreamPortsRequest) error
+	Recv() (*StreamPortsResponse, error)
+	grpc.ClientStream
+}
+
+type portServiceStreamPortsBidiClient struct {
+	grpc.ClientStream
+}
+
+func (x *portServiceStreamPortsBidiClient) Send(m *StreamPortsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *portServiceStreamPortsBidiClient) Recv() (*StreamPortsResponse, error) {
+	m := new(StreamPortsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *portServiceClient) GetPort(ctx context.Context, in *GetPortRequest, opts ...grpc.CallOption) (*GetPortResponse, error) {
+	out := new(GetPortResponse)
+	err := c.cc.Invoke(ctx, PortService_GetPort_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portServiceClient) GetPortHistory(ctx context.Context, in *GetPortHistoryRequest, opts ...grpc.CallOption) (*GetPortHistoryResponse, error) {
+	out := new(GetPortHistoryResponse)
+	err := c.cc.Invoke(ctx, PortService_GetPortHistory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portServiceClient) ListPorts(ctx context.Context, in *ListPortsRequest, opts ...grpc.CallOption) (*ListPortsResponse, error) {
+	out := new(ListPortsResponse)
+	err := c.cc.Invoke(ctx, PortService_ListPorts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portServiceClient) DeletePort(ctx context.Context, in *DeletePortRequest, opts ...grpc.CallOption) (*DeletePortResponse, error) {
+	out := new(DeletePortResponse)
+	err := c.cc.Invoke(ctx, PortService_DeletePort_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portServiceClient) RestorePort(ctx context.Context, in *RestorePortRequest, opts ...grpc.CallOption) (*RestorePortResponse, error) {
+	out := new(RestorePortResponse)
+	err := c.cc.Invoke(ctx, PortService_RestorePort_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portServiceClient) SearchNearby(ctx context.Context, in *SearchNearbyRequest, opts ...grpc.CallOption) (*SearchNearbyResponse, error) {
+	out := new(SearchNearbyResponse)
+	err := c.cc.Invoke(ctx, PortService_SearchNearby_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portServiceClient) FindPorts(ctx context.Context, in *FindPortsRequest, opts ...grpc.CallOption) (*FindPortsResponse, error) {
+	out := new(FindPortsResponse)
+	err := c.cc.Invoke(ctx, PortService_FindPorts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portServiceClient) SearchPorts(ctx context.Context, in *SearchPortsRequest, opts ...grpc.CallOption) (*SearchPortsResponse, error) {
+	out := new(SearchPortsResponse)
+	err := c.cc.Invoke(ctx, PortService_SearchPorts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *portServiceClient) WatchPorts(ctx context.Context, in *WatchPortsRequest, opts ...grpc.CallOption) (PortService_WatchPortsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &PortService_ServiceDesc.Streams[2], PortService_WatchPorts_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &portServiceWatchPortsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PortService_WatchPortsClient interface {
+	Recv() (*PortEvent, error)
+	grpc.ClientStream
+}
+
+type portServiceWatchPortsClient struct {
+	grpc.ClientStream
+}
+
+func (x *portServiceWatchPortsClient) Recv() (*PortEvent, error) {
+	m := new(PortEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // PortServiceServer is the server API for PortService service.
 // All implementations must embed UnimplementedPortServiceServer
 // for forward compatibility
 type PortServiceServer interface {
-	// StreamPorts streams Port objects.
+	// StreamPorts streams Port objects. Once the client closes its side and
+	// every Port has been stored, the server answers with a StreamPortsResponse
+	// carrying the IngestSummary of the call, including the first rejections.
+	// ack is false if any Port was rejected. The call fails if the stream
+	// itself does, e.g. it was cancelled or stayed saturated, and if the store
+	// failed on a Port, with the IngestSummary as a status detail.
 	StreamPorts(PortService_StreamPortsServer) error
+	// StreamPortsBidi streams Port objects and answers every request with a
+	// StreamPortsResponse carrying its uuid once the Port has been stored, or
+	// the reason it could not be.
+	StreamPortsBidi(PortService_StreamPortsBidiServer) error
+	// GetPort returns a single Port by its key, as it is or as it was at a
+	// point in time.
+	GetPort(context.Context, *GetPortRequest) (*GetPortResponse, error)
+	// GetPortHistory returns every stored revision of a Port, oldest first,
+	// including its deletions.
+	GetPortHistory(context.Context, *GetPortHistoryRequest) (*GetPortHistoryResponse, error)
+	// ListPorts returns Port objects ordered by key, one page at a time.
+	ListPorts(context.Context, *ListPortsRequest) (*ListPortsResponse, error)
+	// DeletePort removes a Port by its key. Reads skip the deleted Port unless
+	// they set include_deleted, and RestorePort brings it back, until it is
+	// purged once the server's retention window has passed.
+	DeletePort(context.Context, *DeletePortRequest) (*DeletePortResponse, error)
+	// RestorePort brings back a deleted Port by its key. Fails with
+	// FAILED_PRECONDITION if the Port is not deleted.
+	RestorePort(context.Context, *RestorePortRequest) (*RestorePortResponse, error)
+	// SearchNearby returns the Ports closest to a position, closest first.
+	SearchNearby(context.Context, *SearchNearbyRequest) (*SearchNearbyResponse, error)
+	// FindPorts returns the Ports carrying a UN/LOCODE, code, country or alias,
+	// ordered by key.
+	FindPorts(context.Context, *FindPortsRequest) (*FindPortsResponse, error)
+	// SearchPorts returns the Ports whose name, city, alias or province match
+	// a free-text query, best match first. Matching ignores case and
+	// diacritics and tolerates prefixes and typos.
+	SearchPorts(context.Context, *SearchPortsRequest) (*SearchPortsResponse, error)
+	// WatchPorts streams the changes to the stored Ports, in the order they
+	// were stored, until the client cancels the call. A client reconnecting
+	// passes the resume_token of the last PortEvent it handled to receive the
+	// events after it. Fails with OUT_OF_RANGE once those are no longer
+	// retained, e.g. after a server restart.
+	WatchPorts(*WatchPortsRequest, PortService_WatchPortsServer) error
 	mustEmbedUnimplementedPortServiceServer()
 }
 
@@ -88,6 +311,36 @@ type UnimplementedPortServiceServer struct {
 func (UnimplementedPortServiceServer) StreamPorts(PortService_StreamPortsServer) error {
 	return status.Errorf(codes.Unimplemented, "method StreamPorts not implemented")
 }
+func (UnimplementedPortServiceServer) StreamPortsBidi(PortService_StreamPortsBidiServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamPortsBidi not implemented")
+}
+func (UnimplementedPortServiceServer) GetPort(context.Context, *GetPortRequest) (*GetPortResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPort not implemented")
+}
+func (UnimplementedPortServiceServer) GetPortHistory(context.Context, *GetPortHistoryRequest) (*GetPortHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPortHistory not implemented")
+}
+func (UnimplementedPortServiceServer) ListPorts(context.Context, *ListPortsRequest) (*ListPortsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPorts not implemented")
+}
+func (UnimplementedPortServiceServer) DeletePort(context.Context, *DeletePortRequest) (*DeletePortResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePort not implemented")
+}
+func (UnimplementedPortServiceServer) RestorePort(context.Context, *RestorePortRequest) (*RestorePortResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestorePort not implemented")
+}
+func (UnimplementedPortServiceServer) SearchNearby(context.Context, *SearchNearbyRequest) (*SearchNearbyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchNearby not implemented")
+}
+func (UnimplementedPortServiceServer) FindPorts(context.Context, *FindPortsRequest) (*FindPortsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindPorts not implemented")
+}
+func (UnimplementedPortServiceServer) SearchPorts(context.Context, *SearchPortsRequest) (*SearchPortsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchPorts not implemented")
+}
+func (UnimplementedPortServiceServer) WatchPorts(*WatchPortsRequest, PortService_WatchPortsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchPorts not implemented")
+}
 func (UnimplementedPortServiceServer) mustEmbedUnimplementedPortServiceServer() {}
 
 // UnsafePortServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -127,19 +380,254 @@ func (x *portServiceStreamPortsServer) Recv() (*StreamPortsRequest, error) {
 	return m, nil
 }
 
+func _PortService_StreamPortsBidi_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PortServiceServer).StreamPortsBidi(&portServiceStreamPortsBidiServer{stream})
+}
+
+type PortService_StreamPortsBidiServer interface {
+	Send(*StreamPortsResponse) error
+	Recv() (*StreamPortsRequest, error)
+	grpc.ServerStream
+}
+
+type portServiceStreamPortsBidiServer struct {
+	grpc.ServerStream
+}
+
+func (x *portServiceStreamPortsBidiServer) Send(m *StreamPortsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *portServiceStreamPortsBidiServer) Recv() (*StreamPortsRequest, error) {
+	m := new(StreamPortsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _PortService_GetPort_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPortRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortServiceServer).GetPort(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PortService_GetPort_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortServiceServer).GetPort(ctx, req.(*GetPortRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PortService_GetPortHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPortHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortServiceServer).GetPortHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PortService_GetPortHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortServiceServer).GetPortHistory(ctx, req.(*GetPortHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PortService_ListPorts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPortsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortServiceServer).ListPorts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PortService_ListPorts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortServiceServer).ListPorts(ctx, req.(*ListPortsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PortService_DeletePort_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePortRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortServiceServer).DeletePort(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PortService_DeletePort_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortServiceServer).DeletePort(ctx, req.(*DeletePortRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PortService_RestorePort_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestorePortRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortServiceServer).RestorePort(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PortService_RestorePort_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortServiceServer).RestorePort(ctx, req.(*RestorePortRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PortService_SearchNearby_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchNearbyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortServiceServer).SearchNearby(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PortService_SearchNearby_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortServiceServer).SearchNearby(ctx, req.(*SearchNearbyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PortService_FindPorts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindPortsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortServiceServer).FindPorts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PortService_FindPorts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortServiceServer).FindPorts(ctx, req.(*FindPortsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PortService_SearchPorts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchPortsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PortServiceServer).SearchPorts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PortService_SearchPorts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PortServiceServer).SearchPorts(ctx, req.(*SearchPortsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PortService_WatchPorts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchPortsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PortServiceServer).WatchPorts(m, &portServiceWatchPortsServer{stream})
+}
+
+type PortService_WatchPortsServer interface {
+	Send(*PortEvent) error
+	grpc.ServerStream
+}
+
+type portServiceWatchPortsServer struct {
+	grpc.ServerStream
+}
+
+func (x *portServiceWatchPortsServer) Send(m *PortEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // PortService_ServiceDesc is the grpc.ServiceDesc for PortService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var PortService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "api.PortService",
 	HandlerType: (*PortServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetPort",
+			Handler:    _PortService_GetPort_Handler,
+		},
+		{
+			MethodName: "GetPortHistory",
+			Handler:    _PortService_GetPortHistory_Handler,
+		},
+		{
+			MethodName: "ListPorts",
+			Handler:    _PortService_ListPorts_Handler,
+		},
+		{
+			MethodName: "DeletePort",
+			Handler:    _PortService_DeletePort_Handler,
+		},
+		{
+			MethodName: "RestorePort",
+			Handler:    _PortService_RestorePort_Handler,
+		},
+		{
+			MethodName: "SearchNearby",
+			Handler:    _PortService_SearchNearby_Handler,
+		},
+		{
+			MethodName: "FindPorts",
+			Handler:    _PortService_FindPorts_Handler,
+		},
+		{
+			MethodName: "SearchPorts",
+			Handler:    _PortService_SearchPorts_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "StreamPorts",
 			Handler:       _PortService_StreamPorts_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "StreamPortsBidi",
+			Handler:       _PortService_StreamPortsBidi_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchPorts",
+			Handler:       _PortService_WatchPorts_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "ports_service.proto",
 }
@@ -3,17 +3,110 @@ package ports
 // package ports defines interfaces for external system
 // integrations that are implemented by adapter layer.
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is matched (via errors.Is) by every error a Store
+// returns for a key that holds no value.
+var ErrNotFound = errors.New("not found")
+
+// NotFoundError reports the key a lookup failed for. It matches
+// ErrNotFound so callers can check for it without a type assertion.
+type NotFoundError struct {
+	Key string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("key %q: %v", e.Key, ErrNotFound)
+}
+
+// Is makes errors.Is(err, ErrNotFound) true for a *NotFoundError.
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ListOptions controls a single List call.
+type ListOptions[T any] struct {
+	// PageToken is the NextPageToken of a previous Page, or empty
+	// to start from the beginning.
+	PageToken string
+	// PageSize is the maximum number of items returned. Adapters
+	// apply their own default when it is zero or negative.
+	PageSize int
+	// Filter, when set, drops every value it returns false for.
+	Filter func(T) bool
+}
+
+// Page is one slice of a paginated List result.
+type Page[T any] struct {
+	Items []T
+	// NextPageToken is empty once the last page has been returned.
+	NextPageToken string
+}
+
+// UpsertOutcome tells what Store.Upsert did.
+type UpsertOutcome int
+
+const (
+	// Created means the key held no value before.
+	Created UpsertOutcome = iota + 1
+	// Updated means a different value was replaced.
+	Updated
+	// Unchanged means an equal value was already stored, nothing was written.
+	Unchanged
+)
+
+func (o UpsertOutcome) String() string {
+	switch o {
+	case Created:
+		return "created"
+	case Updated:
+		return "updated"
+	case Unchanged:
+		return "unchanged"
+	default:
+		return fmt.Sprintf("UpsertOutcome(%d)", int(o))
+	}
+}
 
 // Store is a generic persistence interface for saving,
-// data elements. The T type parameter allows loose coupling for the value objects
-// to be stored without assuming specific implementation.
+// retrieving and removing data elements. The T type parameter allows loose
+// coupling for the value objects to be stored without assuming specific
+// implementation.
 type Store[T any] interface {
-	// Set stores a value for a given key
+	// Set stores a value for a given key, like Upsert.
 	Set(ctx context.Context, key string, value T) error
 
-	// Similarly Get and Delete would define
-	// access capabilities.
+	// Upsert stores value for key and reports whether that created or
+	// updated it. Storing a value equal to the one already stored writes
+	// nothing, not even to indexes or logs, and reports Unchanged.
+	Upsert(ctx context.Context, key string, value T) (UpsertOutcome, error)
+
+	// Update stores the value fn derives from the one stored for key, or
+	// from nil if there is none, like Upsert. Reading the old value and
+	// storing the new one is atomic with regard to the other writes. An
+	// error of fn is returned as is, and nothing is stored.
+	Update(ctx context.Context, key string, fn func(old *T) (T, error)) (UpsertOutcome, error)
+
+	// Get returns the value stored for key, or an error matching
+	// ErrNotFound if there is none.
+	Get(ctx context.Context, key string) (T, error)
+
+	// Delete removes the value stored for key, or returns an error
+	// matching ErrNotFound if there is none.
+	Delete(ctx context.Context, key string) error
+
+	// DeleteIf removes the value stored for key if cond, called with it,
+	// returns true, and reports whether it did. Checking and removing the
+	// value is atomic with regard to the other writes. It returns an error
+	// matching ErrNotFound if there is no value.
+	DeleteIf(ctx context.Context, key string, cond func(T) bool) (bool, error)
+
+	// List returns the stored values ordered by key, one page at a time.
+	List(ctx context.Context, opts ListOptions[T]) (Page[T], error)
 
 	// Concrete implementing adapters would
 	// provide specifics like serialization,
@@ -3,7 +3,10 @@ package ports
 // package ports defines interfaces for external system
 // integrations that are implemented by adapter layer.
 
-import "context"
+import (
+	"context"
+	"reflect"
+)
 
 // Streamer is a generic interface that exposes a stream
 // processing/consumption capability for objects of
@@ -21,3 +24,18 @@ type Streamer[T any] interface {
 	// StreamObjects bufferSize hints at desired channel buffer length.
 	StreamObjects(ctx context.Context, bufferSize int) (<-chan T, error)
 }
+
+// SetKey sets the field "Key" to the given value on the passed
+// struct pointer item, if the field exists. Other types are left alone.
+// Helpful to preserve metadata e.g. Key that would be lost otherwise.
+func SetKey[T any](item *T, value string) {
+	t := reflect.TypeOf(item).Elem()
+	v := reflect.ValueOf(item).Elem()
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	if field, ok := t.FieldByName("Key"); ok && field.Type.Kind() == reflect.String {
+		v.FieldByIndex(field.Index).SetString(value)
+	}
+}
@@ -9,10 +9,11 @@ import (
 	"os"
 	"time"
 
-	"ports-service/internal/adapters/streamfromfile"
 	"ports-service/internal/domain"
+	"ports-service/internal/ports"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 
 	"github.com/google/uuid"
 	pb "ports-service/internal/gen/grpc"
@@ -87,7 +88,7 @@ func main() {
 			break
 		}
 
-		streamfromfile.SetKey(&item, key.(string))
+		ports.SetKey(&item, key.(string))
 
 		fmt.Println("sending the following data: ", item)
 
@@ -98,26 +99,39 @@ func main() {
 		req := &pb.StreamPortsRequest{
 			Uuid: id.String(),
 			Port: &pb.Port{
-				Key:         item.Key,
-				Name:        item.Name,
-				City:        item.City,
-				Country:     item.Country,
-				Alias:       item.Alias,
-				Regions:     item.Regions,
-				Coordinates: item.Coordinates,
-				Province:    item.Province,
-				Timezone:    item.Timezone,
-				Unlocs:      item.Unlocs,
-				Code:        item.Code,
+				Key:      item.Key,
+				Name:     item.Name,
+				City:     item.City,
+				Country:  item.Country,
+				Alias:    item.Alias,
+				Regions:  item.Regions,
+				Province: item.Province,
+				Timezone: item.Timezone,
+				Unlocs:   item.Unlocs,
+				Code:     item.Code,
 			},
 		}
+		if item.Coordinates != nil {
+			req.Port.Location = &pb.GeoPoint{Latitude: item.Coordinates.Lat, Longitude: item.Coordinates.Lon}
+		}
 
 		if stream.Send(req); err != nil {
 			log.Printf("Error sending data to server: %v\n", err)
 		}
 	}
 
-	if stream.CloseAndRecv(); err != nil {
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
 		log.Printf("Error closing stream: %v\n", err)
+		for _, detail := range status.Convert(err).Details() {
+			if summary, ok := detail.(*pb.IngestSummary); ok {
+				log.Printf("Ingest summary: %v\n", summary)
+			}
+		}
+		return
+	}
+	if !resp.GetAck() {
+		log.Printf("Not every port was stored: %s\n", resp.GetError())
 	}
+	log.Printf("Ingest summary: %v\n", resp.GetSummary())
 }